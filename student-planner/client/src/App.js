@@ -69,9 +69,7 @@ function App() {
       created_at: userData.created_at
     };
     
-    const token = `user-${userToSave.id}-${Date.now()}`;
-    
-    localStorage.setItem('token', token);
+    localStorage.setItem('token', userData.token);
     localStorage.setItem('user', JSON.stringify(userToSave));
     setIsAuthenticated(true);
     setUser(userToSave);
@@ -82,6 +80,7 @@ function App() {
   };
 
   const handleLogout = () => {
+    authAPI.logout(localStorage.getItem('token')).catch(() => {});
     localStorage.removeItem('token');
     localStorage.removeItem('user');
     setIsAuthenticated(false);
@@ -7,6 +7,8 @@ const Login = ({ onLogin }) => {
     email: '',
     password: '',
   });
+  const [challenge, setChallenge] = useState(null);
+  const [code, setCode] = useState('');
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(false);
   const navigate = useNavigate();
@@ -24,7 +26,18 @@ const Login = ({ onLogin }) => {
     setLoading(true);
 
     try {
+      if (challenge) {
+        const response = await authAPI.loginTwoFactor(challenge, code);
+        onLogin(response);
+        navigate('/');
+        return;
+      }
+
       const response = await authAPI.login(formData);
+      if (response.data.two_factor_required) {
+        setChallenge(response.data.challenge);
+        return;
+      }
       onLogin(response);
       navigate('/');
     } catch (err) {
@@ -41,6 +54,22 @@ const Login = ({ onLogin }) => {
       {error && <div className="error">{error}</div>}
       
       <form className="auth-form" onSubmit={handleSubmit}>
+        {challenge ? (
+          <div className="form-group">
+            <label htmlFor="code">Код из приложения или резервный код</label>
+            <input
+              type="text"
+              id="code"
+              name="code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              autoComplete="one-time-code"
+              placeholder="123456"
+              required
+            />
+          </div>
+        ) : (
+        <>
         <div className="form-group">
           <label htmlFor="email">Email</label>
           <input
@@ -66,6 +95,8 @@ const Login = ({ onLogin }) => {
             required
           />
         </div>
+        </>
+        )}
         
         <button type="submit" className="btn btn-primary" disabled={loading}>
           {loading ? 'Загрузка...' : (
@@ -36,6 +36,7 @@ const Register = ({ onRegister }) => {
         name: formData.name,
         email: formData.email,
         password: formData.password,
+        timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
       });
       onRegister(response);
       navigate('/');
@@ -12,33 +12,14 @@ const api = axios.create({
   withCredentials: false,
 });
 
-const getUserId = () => {
-  const userStr = localStorage.getItem('user');
-  if (userStr) {
-    try {
-      const user = JSON.parse(userStr);
-      return user.id || user.ID;
-    } catch (error) {
-      console.error('Error parsing user:', error);
-      return null;
-    }
-  }
-  return null;
-};
-
 api.interceptors.request.use(
   (config) => {
     const token = localStorage.getItem('token');
-    const userId = getUserId();
     
     if (token) {
       config.headers.Authorization = `Bearer ${token}`;
     }
     
-    if (userId) {
-      config.headers['X-User-ID'] = userId.toString();
-    }
-    
     return config;
   },
   (error) => {
@@ -72,31 +53,164 @@ export const testConnection = () => {
 export const authAPI = {
   register: (userData) => api.post('/register', userData),
   login: (credentials) => api.post('/login', credentials),
+  loginTwoFactor: (challenge, code) => api.post('/login/2fa', { challenge, code }),
+  logout: (token) => api.post('/logout', null, { headers: { Authorization: `Bearer ${token}` } }),
   checkAuth: () => api.get('/check-auth'),
 };
 
+export const twoFactorAPI = {
+  getStatus: () => api.get('/2fa'),
+  setup: () => api.post('/2fa/setup'),
+  enable: (code) => api.post('/2fa/enable', { code }),
+  disable: (code) => api.post('/2fa/disable', { code }),
+  regenerateRecoveryCodes: (code) => api.post('/2fa/recovery-codes', { code }),
+};
+
+export const profileAPI = {
+  getProfile: () => api.get('/profile'),
+  updateProfile: (profileData) => api.put('/profile', profileData),
+};
+
+// ifMatch передаёт ETag, полученный с объектом: сервер отклонит изменение с 412,
+// если объект успели изменить в другом месте.
+const ifMatch = (etag, headers = {}) => ({ headers: etag ? { ...headers, 'If-Match': etag } : headers });
+
 export const eventsAPI = {
-  getEvents: () => api.get('/events'),
+  getEvents: (params) => api.get('/events', { params }),
+  getEvent: (id) => api.get(`/events/${id}`),
   createEvent: (eventData) => api.post('/events', eventData),
-  updateEvent: (id, eventData) => api.put(`/events/${id}`, eventData),
-  deleteEvent: (id) => api.delete(`/events/${id}`),
+  updateEvent: (id, eventData, etag) => api.put(`/events/${id}`, eventData, ifMatch(etag)),
+  patchEvent: (id, changes, etag) => api.patch(`/events/${id}`, changes, ifMatch(etag, { 'Content-Type': 'application/merge-patch+json' })),
+  deleteEvent: (id, etag) => api.delete(`/events/${id}`, ifMatch(etag)),
+  bulk: (operations, mode = 'atomic') => api.post('/events/bulk', { mode, operations }),
+  getShares: (id) => api.get(`/events/${id}/shares`),
+  share: (id, email, permission = 'read') => api.post(`/events/${id}/shares`, { email, permission }),
+  revokeShare: (id, userId) => api.delete(`/events/${id}/shares/${userId}`),
+  markAttendance: (id, status, note) => api.put(`/events/${id}/attendance`, { status, note }),
+  clearAttendance: (id) => api.delete(`/events/${id}/attendance`),
+  getHistory: (id) => api.get(`/events/${id}/history`),
+  revert: (id, historyId) => api.post(`/events/${id}/history/${historyId}/revert`),
 };
 
 export const tasksAPI = {
-  getTasks: () => api.get('/tasks'),
+  getTasks: (params) => api.get('/tasks', { params }),
+  getTask: (id) => api.get(`/tasks/${id}`),
   createTask: (taskData) => api.post('/tasks', taskData),
-  updateTask: (id, taskData) => api.put(`/tasks/${id}`, taskData),
-  toggleTaskCompletion: (id, isCompleted) => api.put(`/tasks/${id}/toggle`, { is_completed: isCompleted }),
-  deleteTask: (id) => api.delete(`/tasks/${id}`),
+  updateTask: (id, taskData, etag) => api.put(`/tasks/${id}`, taskData, ifMatch(etag)),
+  patchTask: (id, changes, etag) => api.patch(`/tasks/${id}`, changes, ifMatch(etag, { 'Content-Type': 'application/merge-patch+json' })),
+  toggleTaskCompletion: (id, isCompleted, cascade = false, force = false) => api.put(`/tasks/${id}/toggle`, { is_completed: isCompleted, cascade, force }),
+  updateTaskStatus: (id, status, force = false) => api.put(`/tasks/${id}/status`, { status, force }),
+  getBoard: (params) => api.get('/tasks/board', { params }),
+  reorderSubtasks: (id, order) => api.put(`/tasks/${id}/subtasks/order`, { order }),
+  getDependencies: (id) => api.get(`/tasks/${id}/dependencies`),
+  addDependency: (id, dependsOnId) => api.post(`/tasks/${id}/dependencies`, { depends_on_id: dependsOnId }),
+  removeDependency: (id, dependsOnId) => api.delete(`/tasks/${id}/dependencies/${dependsOnId}`),
+  getHistory: (id) => api.get(`/tasks/${id}/history`),
+  revert: (id, historyId) => api.post(`/tasks/${id}/history/${historyId}/revert`),
+  deleteTask: (id, etag) => api.delete(`/tasks/${id}`, ifMatch(etag)),
+  bulk: (operations, mode = 'atomic') => api.post('/tasks/bulk', { mode, operations }),
+  getShares: (id) => api.get(`/tasks/${id}/shares`),
+  share: (id, email, permission = 'read') => api.post(`/tasks/${id}/shares`, { email, permission }),
+  revokeShare: (id, userId) => api.delete(`/tasks/${id}/shares/${userId}`),
+};
+
+export const sharedAPI = {
+  getSharedWithMe: () => api.get('/shared'),
+};
+
+export const groupsAPI = {
+  getGroups: () => api.get('/groups'),
+  getGroup: (id) => api.get(`/groups/${id}`),
+  createGroup: (name) => api.post('/groups', { name }),
+  updateGroup: (id, name) => api.put(`/groups/${id}`, { name }),
+  deleteGroup: (id) => api.delete(`/groups/${id}`),
+  regenerateInviteCode: (id) => api.post(`/groups/${id}/invite-code`),
+  joinGroup: (code) => api.post('/groups/join', { code }),
+  invite: (id, email, role = 'viewer') => api.post(`/groups/${id}/invites`, { email, role }),
+  getInvites: () => api.get('/groups/invites'),
+  acceptInvite: (inviteId) => api.post(`/groups/invites/${inviteId}`),
+  declineInvite: (inviteId) => api.delete(`/groups/invites/${inviteId}`),
+  updateMember: (id, userId, role) => api.put(`/groups/${id}/members/${userId}`, { role }),
+  removeMember: (id, userId) => api.delete(`/groups/${id}/members/${userId}`),
+  getEvents: (id) => api.get(`/groups/${id}/events`),
+  setEventHidden: (id, eventId, hidden) => api.put(`/groups/${id}/events/${eventId}/override`, { hidden }),
+  clearEventOverride: (id, eventId) => api.delete(`/groups/${id}/events/${eventId}/override`),
+  getAttendance: (id) => api.get(`/groups/${id}/attendance`),
+};
+
+export const adminAPI = {
+  getUsers: (params) => api.get('/admin/users', { params }),
+  lockUser: (id) => api.put(`/admin/users/${id}/lock`),
+  unlockUser: (id) => api.put(`/admin/users/${id}/unlock`),
+  setRole: (id, role) => api.put(`/admin/users/${id}/role`, { role }),
+  resetPassword: (id, password) => api.post(`/admin/users/${id}/reset-password`, password ? { password } : {}),
+  getStats: () => api.get('/admin/stats'),
 };
 
 export const scheduleAPI = {
-  getSchedule: () => api.get('/schedule'),
+  getSchedule: (params) => api.get('/schedule', { params }),
   getWeekSchedule: () => api.get('/schedule/week'),
+  getDaySchedule: (date) => api.get('/schedule/day', { params: { date } }),
+  getMonthSchedule: (month) => api.get('/schedule/month', { params: { month } }),
+  getAgenda: (from, to) => api.get('/schedule/agenda', { params: { from, to } }),
+};
+
+export const termsAPI = {
+  getTerms: (archived) => api.get('/terms', { params: { archived } }),
+  createTerm: (termData) => api.post('/terms', termData),
+  updateTerm: (id, termData) => api.put(`/terms/${id}`, termData),
+  deleteTerm: (id) => api.delete(`/terms/${id}`),
+  activateTerm: (id) => api.put(`/terms/${id}/activate`),
+  archiveTerm: (id) => api.put(`/terms/${id}/archive`),
+  unarchiveTerm: (id) => api.put(`/terms/${id}/unarchive`),
+};
+
+export const subjectsAPI = {
+  getSubjects: () => api.get('/subjects'),
+  createSubject: (subjectData) => api.post('/subjects', subjectData),
+  updateSubject: (id, subjectData) => api.put(`/subjects/${id}`, subjectData),
+  deleteSubject: (id) => api.delete(`/subjects/${id}`),
+};
+
+export const teachersAPI = {
+  getTeachers: (q) => api.get('/teachers', { params: { q } }),
+  createTeacher: (teacherData) => api.post('/teachers', teacherData),
+  updateTeacher: (id, teacherData) => api.put(`/teachers/${id}`, teacherData),
+  deleteTeacher: (id) => api.delete(`/teachers/${id}`),
+};
+
+export const roomsAPI = {
+  getRooms: (q) => api.get('/rooms', { params: { q } }),
+  createRoom: (roomData) => api.post('/rooms', roomData),
+  updateRoom: (id, roomData) => api.put(`/rooms/${id}`, roomData),
+  deleteRoom: (id) => api.delete(`/rooms/${id}`),
+};
+
+export const gradesAPI = {
+  getGrades: (params) => api.get('/grades', { params }),
+  createGrade: (gradeData) => api.post('/grades', gradeData),
+  updateGrade: (id, gradeData) => api.put(`/grades/${id}`, gradeData),
+  deleteGrade: (id) => api.delete(`/grades/${id}`),
+};
+
+export const tagsAPI = {
+  getTags: () => api.get('/tags'),
+  createTag: (tagData) => api.post('/tags', tagData),
+  updateTag: (id, tagData) => api.put(`/tags/${id}`, tagData),
+  deleteTag: (id) => api.delete(`/tags/${id}`),
+};
+
+export const trashAPI = {
+  getTrash: () => api.get('/trash'),
+  restore: (type, id) => api.post(`/trash/${type}/${id}/restore`),
 };
 
 export const statsAPI = {
-  getStats: () => api.get('/stats'),
+  getStats: (params) => api.get('/stats', { params }),
+  getSubjectStats: () => api.get('/stats/subjects'),
+  getGradeStats: (params) => api.get('/stats/grades', { params }),
+  getAttendanceStats: (params) => api.get('/stats/attendance', { params }),
+  getTagStats: (params) => api.get('/stats/tags', { params }),
 };
 
 export default api;
\ No newline at end of file
@@ -0,0 +1,302 @@
+package main
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "encoding/base32"
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+type AdminUser struct {
+    ID        int        `json:"id"`
+    Email     string     `json:"email"`
+    Name      string     `json:"name"`
+    Role      string     `json:"role"`
+    LockedAt  *time.Time `json:"locked_at"`
+    Events    int        `json:"events"`
+    Tasks     int        `json:"tasks"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+type SystemStats struct {
+    Users         int            `json:"users"`
+    LockedUsers   int            `json:"locked_users"`
+    UsersByRole   map[string]int `json:"users_by_role"`
+    NewUsersWeek  int            `json:"new_users_week"`
+    Events        int            `json:"events"`
+    Tasks         int            `json:"tasks"`
+    TrashedEvents int            `json:"trashed_events"`
+    TrashedTasks  int            `json:"trashed_tasks"`
+    Groups        int            `json:"groups"`
+    Shares        int            `json:"shares"`
+    HistoryRows   int            `json:"history_rows"`
+}
+
+const adminUserColumns = `u.id, u.email, u.name, u.role, u.locked_at,
+                (SELECT COUNT(*) FROM events e WHERE e.user_id = u.id AND e.deleted_at IS NULL),
+                (SELECT COUNT(*) FROM tasks t WHERE t.user_id = u.id AND t.deleted_at IS NULL),
+                u.created_at`
+
+func scanAdminUser(row rowScanner) (AdminUser, error) {
+    var user AdminUser
+    var lockedAt sql.NullTime
+    err := row.Scan(
+        &user.ID, &user.Email, &user.Name, &user.Role, &lockedAt,
+        &user.Events, &user.Tasks, &user.CreatedAt,
+    )
+    user.LockedAt = nullTimePtr(lockedAt)
+    return user, err
+}
+
+// promoteConfiguredAdmin назначает администратором пользователя с email из ADMIN_EMAIL,
+// чтобы на новой установке было кому выдавать роли.
+func promoteConfiguredAdmin(cfg Config) {
+    if cfg.AdminEmail == "" {
+        return
+    }
+
+    promoted, err := promoteAdmin(cfg.AdminEmail)
+    if err != nil {
+        log.Printf("Ошибка назначения администратора %s: %v", cfg.AdminEmail, err)
+    } else if promoted {
+        log.Printf("Пользователь %s назначен администратором", cfg.AdminEmail)
+    }
+}
+
+// newTemporaryPassword генерирует временный пароль для сброса администратором.
+func newTemporaryPassword() (string, error) {
+    buf := make([]byte, 10)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return strings.ToLower(base32.StdEncoding.EncodeToString(buf)), nil
+}
+
+func loadAdminUser(userID int) (AdminUser, error) {
+    return scanAdminUser(db.QueryRow(`SELECT `+adminUserColumns+` FROM users u WHERE u.id = $1`, userID))
+}
+
+// AdminGetUsers возвращает пользователей с фильтрами ?q= (email или имя), ?role= и ?locked=true.
+func AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+    if authorize(w, r, actionManageUsers) == 0 {
+        return
+    }
+
+    query := r.URL.Query()
+    users, err := listUsers(query.Get("q"), query.Get("role"), query.Get("locked") == "true")
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения пользователей"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(users)
+}
+
+// setUserLocked блокирует или разблокирует учётную запись. Себя заблокировать нельзя,
+// чтобы не остаться без администратора.
+func setUserLocked(w http.ResponseWriter, r *http.Request, locked bool) {
+    adminID := authorize(w, r, actionManageUsers)
+    if adminID == 0 {
+        return
+    }
+
+    vars := mux.Vars(r)
+    userID, _ := strconv.Atoi(vars["id"])
+
+    if locked && userID == adminID {
+        writeBadRequest(w, "Нельзя заблокировать собственную учётную запись")
+        return
+    }
+
+    found, err := updateUserLock(userID, locked)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка изменения учётной записи"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if !found {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    }
+
+    user, err := loadAdminUser(userID)
+    if err != nil {
+        http.Error(w, `{"error": "Учётная запись изменена, но не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(user)
+}
+
+func AdminLockUser(w http.ResponseWriter, r *http.Request) {
+    setUserLocked(w, r, true)
+}
+
+func AdminUnlockUser(w http.ResponseWriter, r *http.Request) {
+    setUserLocked(w, r, false)
+}
+
+// AdminSetUserRole меняет роль пользователя. Администратор не может понизить сам себя.
+func AdminSetUserRole(w http.ResponseWriter, r *http.Request) {
+    adminID := authorize(w, r, actionManageUsers)
+    if adminID == 0 {
+        return
+    }
+
+    vars := mux.Vars(r)
+    userID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Role string `json:"role"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if !userRoles[req.Role] {
+        writeBadRequest(w, "Роль должна быть student, group_leader или admin")
+        return
+    }
+    if userID == adminID && req.Role != roleAdmin {
+        writeBadRequest(w, "Нельзя снять роль администратора с самого себя")
+        return
+    }
+
+    found, err := updateUserRole(userID, req.Role)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка изменения роли"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if !found {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    }
+
+    user, err := loadAdminUser(userID)
+    if err != nil {
+        http.Error(w, `{"error": "Роль изменена, но пользователь не получен"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(user)
+}
+
+// AdminResetPassword задаёт пользователю новый пароль. Без пароля в запросе
+// генерируется временный и возвращается в ответе, чтобы передать его пользователю.
+func AdminResetPassword(w http.ResponseWriter, r *http.Request) {
+    if authorize(w, r, actionManageUsers) == 0 {
+        return
+    }
+
+    vars := mux.Vars(r)
+    userID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Password string `json:"password"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    generated := req.Password == ""
+    if generated {
+        password, err := newTemporaryPassword()
+        if err != nil {
+            http.Error(w, `{"error": "Ошибка сброса пароля"}`, http.StatusInternalServerError)
+            return
+        }
+        req.Password = password
+    } else if len(req.Password) < 6 {
+        writeBadRequest(w, "Пароль должен быть не короче 6 символов")
+        return
+    }
+
+    found, err := updateUserPassword(userID, req.Password)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка сброса пароля"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if !found {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    }
+
+    response := map[string]string{"message": "Пароль изменён"}
+    if generated {
+        response["temporary_password"] = req.Password
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// loadSystemStats собирает сводку по системе для подкоманды stats и AdminGetStats.
+func loadSystemStats() (SystemStats, error) {
+    var stats SystemStats
+    err := db.QueryRow(
+        `SELECT
+            (SELECT COUNT(*) FROM users),
+            (SELECT COUNT(*) FROM users WHERE locked_at IS NOT NULL),
+            (SELECT COUNT(*) FROM users WHERE created_at >= CURRENT_TIMESTAMP - INTERVAL '7 days'),
+            (SELECT COUNT(*) FROM events WHERE deleted_at IS NULL),
+            (SELECT COUNT(*) FROM tasks WHERE deleted_at IS NULL),
+            (SELECT COUNT(*) FROM events WHERE deleted_at IS NOT NULL),
+            (SELECT COUNT(*) FROM tasks WHERE deleted_at IS NOT NULL),
+            (SELECT COUNT(*) FROM study_groups),
+            (SELECT COUNT(*) FROM item_shares),
+            (SELECT COUNT(*) FROM change_history)`,
+    ).Scan(
+        &stats.Users, &stats.LockedUsers, &stats.NewUsersWeek, &stats.Events, &stats.Tasks,
+        &stats.TrashedEvents, &stats.TrashedTasks, &stats.Groups, &stats.Shares, &stats.HistoryRows,
+    )
+    if err != nil {
+        return stats, err
+    }
+
+    stats.UsersByRole = map[string]int{roleStudent: 0, roleGroupLeader: 0, roleAdmin: 0}
+    rows, err := db.Query("SELECT role, COUNT(*) FROM users GROUP BY role")
+    if err != nil {
+        return stats, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var role string
+        var count int
+        if err := rows.Scan(&role, &count); err == nil {
+            stats.UsersByRole[role] = count
+        }
+    }
+
+    return stats, rows.Err()
+}
+
+func AdminGetStats(w http.ResponseWriter, r *http.Request) {
+    if authorize(w, r, actionViewSystemStats) == 0 {
+        return
+    }
+
+    stats, err := loadSystemStats()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
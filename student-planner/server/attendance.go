@@ -0,0 +1,303 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+var attendanceStatuses = map[string]bool{
+    "attended": true,
+    "missed":   true,
+    "excused":  true,
+}
+
+// attendanceEventTypes — типы событий, для которых ведётся учёт посещаемости.
+var attendanceEventTypes = map[string]bool{
+    "lecture":  true,
+    "practice": true,
+}
+
+// attendanceColumn подставляет в выборку событий отметку пользователя из параметра viewer:
+// у общего занятия группы у каждого участника своя отметка.
+func attendanceColumn(viewer string) string {
+    return `COALESCE((SELECT status FROM attendance
+                      WHERE attendance.event_id = events.id AND attendance.user_id = ` + viewer + `), '')`
+}
+
+type Attendance struct {
+    EventID  int       `json:"event_id"`
+    Status   string    `json:"status"`
+    Note     string    `json:"note"`
+    MarkedAt time.Time `json:"marked_at"`
+}
+
+func MarkAttendance(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    eventID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Status string `json:"status"`
+        Note   string `json:"note"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if !attendanceStatuses[req.Status] {
+        http.Error(w, `{"error": "Статус должен быть attended, missed или excused"}`, http.StatusBadRequest)
+        return
+    }
+
+    var eventType string
+    var startsAt time.Time
+    err := db.QueryRow(
+        "SELECT event_type, starts_at FROM events WHERE id = $1 AND "+visibleEventsScope("$2")+" AND deleted_at IS NULL",
+        eventID, userID,
+    ).Scan(&eventType, &startsAt)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Событие не найдено или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения события"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if !attendanceEventTypes[eventType] {
+        http.Error(w, `{"error": "Посещаемость отмечается только для лекций и практик"}`, http.StatusBadRequest)
+        return
+    }
+
+    // Уважительную причину можно указать заранее, присутствие и пропуск — только после начала занятия.
+    if req.Status != "excused" && startsAt.After(time.Now()) {
+        http.Error(w, `{"error": "Занятие ещё не началось"}`, http.StatusBadRequest)
+        return
+    }
+
+    var attendance Attendance
+    err = db.QueryRow(
+        `INSERT INTO attendance (event_id, user_id, status, note)
+         VALUES ($1, $2, $3, $4)
+         ON CONFLICT (event_id, user_id) DO UPDATE
+         SET status = EXCLUDED.status, note = EXCLUDED.note, marked_at = CURRENT_TIMESTAMP
+         RETURNING event_id, status, COALESCE(note, ''), marked_at`,
+        eventID, userID, req.Status, req.Note,
+    ).Scan(&attendance.EventID, &attendance.Status, &attendance.Note, &attendance.MarkedAt)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка отметки посещаемости"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(attendance)
+}
+
+func ClearAttendance(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    eventID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM attendance WHERE event_id = $1 AND user_id = $2", eventID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления отметки"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Отметка не найдена"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Отметка удалена"})
+}
+
+func GetAttendanceStats(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    type SubjectAttendance struct {
+        SubjectID         int     `json:"subject_id"`
+        Name              string  `json:"name"`
+        MinAttendance     float64 `json:"min_attendance"`
+        TotalSessions     int     `json:"total_sessions"`
+        PastSessions      int     `json:"past_sessions"`
+        Attended          int     `json:"attended"`
+        Missed            int     `json:"missed"`
+        Excused           int     `json:"excused"`
+        Unmarked          int     `json:"unmarked"`
+        AttendancePercent float64 `json:"attendance_percent"`
+        CanMiss           *int    `json:"can_miss"`
+        Warning           string  `json:"warning,omitempty"`
+    }
+
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+
+    where, args := appendEventTermFilter(
+        visibleEventsScope("$1")+" AND deleted_at IS NULL AND event_type IN ('lecture', 'practice') AND subject_id IS NOT NULL",
+        []interface{}{userID}, term, userLocation(userID),
+    )
+    args = append(args, time.Now())
+    nowParam := fmt.Sprintf("$%d", len(args))
+
+    rows, err := db.Query(
+        `SELECT s.id, s.name, s.min_attendance,
+                COUNT(e.id),
+                COUNT(e.id) FILTER (WHERE e.starts_at <= `+nowParam+`),
+                COUNT(a.event_id) FILTER (WHERE a.status = 'attended'),
+                COUNT(a.event_id) FILTER (WHERE a.status = 'missed'),
+                COUNT(a.event_id) FILTER (WHERE a.status = 'excused'),
+                COUNT(e.id) FILTER (WHERE e.starts_at <= `+nowParam+` AND a.event_id IS NULL)
+         FROM (SELECT id, subject_id, starts_at FROM events WHERE `+where+`) e
+         JOIN subjects s ON s.id = e.subject_id
+         LEFT JOIN attendance a ON a.event_id = e.id AND a.user_id = $1
+         GROUP BY s.id, s.name, s.min_attendance
+         ORDER BY s.name`,
+        args...,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    stats := []SubjectAttendance{}
+    for rows.Next() {
+        var item SubjectAttendance
+        err := rows.Scan(
+            &item.SubjectID, &item.Name, &item.MinAttendance,
+            &item.TotalSessions, &item.PastSessions,
+            &item.Attended, &item.Missed, &item.Excused, &item.Unmarked,
+        )
+        if err != nil {
+            continue
+        }
+
+        if counted := item.Attended + item.Missed; counted > 0 {
+            item.AttendancePercent = roundTo(float64(item.Attended)/float64(counted)*100, 2)
+        }
+
+        // Занятия по уважительной причине не считаются пропусками и не входят в норму.
+        if item.MinAttendance > 0 {
+            required := item.TotalSessions - item.Excused
+            mustAttend := int(math.Ceil(float64(required) * item.MinAttendance / 100))
+            canMiss := required - mustAttend - item.Missed
+            item.CanMiss = &canMiss
+
+            switch {
+            case canMiss < 0:
+                item.Warning = fmt.Sprintf("Норма посещаемости нарушена: пропущено на %d больше допустимого", -canMiss)
+            case canMiss == 0:
+                item.Warning = "Больше пропускать нельзя"
+            case canMiss <= 2:
+                item.Warning = fmt.Sprintf("Можно пропустить ещё %d", canMiss)
+            }
+        }
+
+        stats = append(stats, item)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
+
+// GetGroupAttendance — посещаемость участников группы на её прошедших занятиях.
+// Сводку видят старосты и администраторы, если они владельцы или редакторы группы;
+// занятие, скрытое участником у себя, ему не засчитывается.
+func GetGroupAttendance(w http.ResponseWriter, r *http.Request) {
+    userID := authorize(w, r, actionViewGroupAttendance)
+    if userID == 0 {
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+    if !requireGroupRole(w, groupID, userID, "editor") {
+        return
+    }
+
+    type MemberAttendance struct {
+        UserID            int     `json:"user_id"`
+        Name              string  `json:"name"`
+        Email             string  `json:"email"`
+        PastSessions      int     `json:"past_sessions"`
+        Attended          int     `json:"attended"`
+        Missed            int     `json:"missed"`
+        Excused           int     `json:"excused"`
+        Unmarked          int     `json:"unmarked"`
+        AttendancePercent float64 `json:"attendance_percent"`
+    }
+
+    rows, err := db.Query(
+        `SELECT u.id, u.name, u.email,
+                COUNT(e.id),
+                COUNT(a.event_id) FILTER (WHERE a.status = 'attended'),
+                COUNT(a.event_id) FILTER (WHERE a.status = 'missed'),
+                COUNT(a.event_id) FILTER (WHERE a.status = 'excused'),
+                COUNT(e.id) FILTER (WHERE a.event_id IS NULL)
+         FROM group_members m
+         JOIN users u ON u.id = m.user_id
+         LEFT JOIN events e ON e.group_id = m.group_id AND e.deleted_at IS NULL
+              AND e.event_type IN ('lecture', 'practice') AND e.starts_at <= $2
+              AND e.id NOT IN (SELECT event_id FROM group_event_overrides WHERE user_id = m.user_id AND hidden)
+         LEFT JOIN attendance a ON a.event_id = e.id AND a.user_id = m.user_id
+         WHERE m.group_id = $1
+         GROUP BY u.id, u.name, u.email
+         ORDER BY u.name`,
+        groupID, time.Now(),
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения посещаемости группы"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    members := []MemberAttendance{}
+    for rows.Next() {
+        var item MemberAttendance
+        err := rows.Scan(
+            &item.UserID, &item.Name, &item.Email, &item.PastSessions,
+            &item.Attended, &item.Missed, &item.Excused, &item.Unmarked,
+        )
+        if err != nil {
+            continue
+        }
+
+        if counted := item.Attended + item.Missed; counted > 0 {
+            item.AttendancePercent = roundTo(float64(item.Attended)/float64(counted)*100, 2)
+        }
+        members = append(members, item)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(members)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+)
+
+// maxBulkOperations — предельное число операций в одном пакетном запросе.
+const maxBulkOperations = 200
+
+type bulkOperation struct {
+    Op          string          `json:"op"`
+    ID          int             `json:"id"`
+    Data        json.RawMessage `json:"data"`
+    IsCompleted bool            `json:"is_completed"`
+    Force       bool            `json:"force"`
+}
+
+type bulkRequest struct {
+    Mode       string          `json:"mode"`
+    Operations []bulkOperation `json:"operations"`
+}
+
+type BulkResult struct {
+    Index  int    `json:"index"`
+    Op     string `json:"op"`
+    ID     int    `json:"id,omitempty"`
+    Status int    `json:"status"`
+    Error  string `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+    Mode      string       `json:"mode"`
+    Committed bool         `json:"committed"`
+    Succeeded int          `json:"succeeded"`
+    Failed    int          `json:"failed"`
+    Results   []BulkResult `json:"results"`
+}
+
+// bulkHistory — запись журнала, которая делается только после фиксации транзакции.
+type bulkHistory struct {
+    id     int
+    action string
+    before json.RawMessage
+}
+
+// bulkApplier выполняет одну операцию внутри транзакции и возвращает id объекта.
+type bulkApplier func(tx *sql.Tx, userID int, op bulkOperation) (int, error)
+
+var bulkOps = map[string]bool{
+    "create": true,
+    "update": true,
+    "delete": true,
+    "toggle": true,
+}
+
+// runBulk выполняет пакет операций в одной транзакции. В режиме atomic первая ошибка
+// откатывает весь пакет; в режиме best_effort каждая операция изолирована точкой
+// сохранения, и откатываются только неудавшиеся. После фиксации пишется журнал
+// изменений и вызывается afterCommit для успешных операций.
+func runBulk(w http.ResponseWriter, r *http.Request, entityType string, apply bulkApplier, afterCommit func(userID int, op bulkOperation, id int)) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req bulkRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if req.Mode == "" {
+        req.Mode = "atomic"
+    }
+    if req.Mode != "atomic" && req.Mode != "best_effort" {
+        writeBadRequest(w, "Режим должен быть atomic или best_effort")
+        return
+    }
+    if len(req.Operations) == 0 {
+        writeBadRequest(w, "Передайте хотя бы одну операцию")
+        return
+    }
+    if len(req.Operations) > maxBulkOperations {
+        http.Error(w, fmt.Sprintf(`{"error": "Не больше %d операций за один запрос"}`, maxBulkOperations), http.StatusRequestEntityTooLarge)
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка выполнения пакета"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    response := BulkResponse{Mode: req.Mode, Results: []BulkResult{}}
+    history := []bulkHistory{}
+    succeeded := []BulkResult{}
+
+    for i, op := range req.Operations {
+        result := BulkResult{Index: i, Op: op.Op, ID: op.ID}
+
+        if !bulkOps[op.Op] {
+            err = saveFail(http.StatusBadRequest, "Операция должна быть create, update, delete или toggle")
+        } else {
+            var before json.RawMessage
+            if op.Op != "create" {
+                before = snapshotRow(entityType, op.ID)
+            }
+
+            if req.Mode == "best_effort" {
+                tx.Exec("SAVEPOINT bulk_item")
+            }
+            var id int
+            id, err = apply(tx, userID, op)
+            if req.Mode == "best_effort" {
+                if err != nil {
+                    tx.Exec("ROLLBACK TO SAVEPOINT bulk_item")
+                } else {
+                    tx.Exec("RELEASE SAVEPOINT bulk_item")
+                }
+            }
+
+            if err == nil {
+                result.ID = id
+                history = append(history, bulkHistory{id: id, action: op.Op, before: before})
+            }
+        }
+
+        if err != nil {
+            result.Status = http.StatusInternalServerError
+            result.Error = "Ошибка выполнения операции"
+            switch failure := err.(type) {
+            case *saveError:
+                result.Status, result.Error = failure.status, failure.message
+            case *roomConflictError, *transitionError:
+                result.Status, result.Error = http.StatusConflict, failure.Error()
+            default:
+                log.Printf("Ошибка пакетной операции %s #%d: %v", entityType, i, err)
+            }
+            response.Failed++
+            response.Results = append(response.Results, result)
+
+            if req.Mode == "atomic" {
+                break
+            }
+            continue
+        }
+
+        result.Status = http.StatusOK
+        if op.Op == "create" {
+            result.Status = http.StatusCreated
+        }
+        response.Succeeded++
+        response.Results = append(response.Results, result)
+        succeeded = append(succeeded, result)
+    }
+
+    if req.Mode == "atomic" && response.Failed > 0 {
+        // Уже выполненные операции откатываются вместе с пакетом.
+        failedIndex := response.Results[len(response.Results)-1].Index
+        for i := range response.Results[:len(response.Results)-1] {
+            result := &response.Results[i]
+            result.Status = http.StatusFailedDependency
+            result.Error = fmt.Sprintf("Отменено из-за ошибки в операции %d", failedIndex)
+            if result.Op == "create" {
+                result.ID = 0
+            }
+        }
+        response.Succeeded = 0
+
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusUnprocessableEntity)
+        json.NewEncoder(w).Encode(response)
+        return
+    }
+
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка выполнения пакета"}`, http.StatusInternalServerError)
+        return
+    }
+    response.Committed = true
+
+    for _, entry := range history {
+        recordHistory(entityType, entry.id, userID, entry.action, entry.before)
+    }
+    if afterCommit != nil {
+        for _, result := range succeeded {
+            afterCommit(userID, req.Operations[result.Index], result.ID)
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// BulkEvents выполняет пакет операций create, update и delete над событиями.
+func BulkEvents(w http.ResponseWriter, r *http.Request) {
+    runBulk(w, r, "event", applyBulkEvent, nil)
+}
+
+// BulkTasks выполняет пакет операций create, update, delete и toggle над задачами.
+// Следующие экземпляры повторяющихся задач создаются после фиксации пакета.
+func BulkTasks(w http.ResponseWriter, r *http.Request) {
+    runBulk(w, r, "task", applyBulkTask, func(userID int, op bulkOperation, id int) {
+        completed := op.Op == "toggle" && op.IsCompleted
+        if op.Op == "update" || op.Op == "create" {
+            var data taskInput
+            json.Unmarshal(op.Data, &data)
+            completed = data.IsCompleted
+        }
+        if completed {
+            if err := spawnNextOccurrence(id, userID); err != nil {
+                log.Printf("Ошибка создания повторения задачи %d: %v", id, err)
+            }
+        }
+    })
+}
+
+func applyBulkEvent(tx *sql.Tx, userID int, op bulkOperation) (int, error) {
+    switch op.Op {
+    case "create", "update":
+        var req eventInput
+        if err := json.Unmarshal(op.Data, &req); err != nil {
+            return 0, saveFail(http.StatusBadRequest, "Неверный формат данных")
+        }
+        if msg := validateEventInput(req); msg != "" {
+            return 0, saveFail(http.StatusBadRequest, msg)
+        }
+        return storeEvent(tx, userID, op.ID, 0, req)
+
+    case "delete":
+        result, err := tx.Exec(
+            "UPDATE events SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND "+editableEventsScope("$2")+" AND deleted_at IS NULL",
+            op.ID, userID,
+        )
+        if err != nil {
+            return 0, err
+        }
+        if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+            return 0, saveFail(http.StatusNotFound, "Событие не найдено или нет прав доступа")
+        }
+        return op.ID, nil
+    }
+    return 0, saveFail(http.StatusBadRequest, "Операция toggle доступна только для задач")
+}
+
+func applyBulkTask(tx *sql.Tx, userID int, op bulkOperation) (int, error) {
+    switch op.Op {
+    case "create", "update":
+        var req struct {
+            taskInput
+            ParentID *int `json:"parent_id"`
+        }
+        if err := json.Unmarshal(op.Data, &req); err != nil {
+            return 0, saveFail(http.StatusBadRequest, "Неверный формат данных")
+        }
+        if req.Priority == "" {
+            req.Priority = "medium"
+        }
+        if msg := validateTaskInput(req.taskInput); msg != "" {
+            return 0, saveFail(http.StatusBadRequest, msg)
+        }
+        taskID, _, err := storeTask(tx, userID, op.ID, 0, req.ParentID, req.taskInput)
+        return taskID, err
+
+    case "toggle":
+        status, err := bulkTaskCompletion(tx, op.ID, userID, op.IsCompleted, op.Force)
+        if err != nil {
+            return 0, err
+        }
+
+        result, err := tx.Exec(
+            `UPDATE tasks
+             SET `+taskStatusAssignments("$3::varchar")+`
+             WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`,
+            op.ID, userID, status,
+        )
+        if err != nil {
+            return 0, err
+        }
+        if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+            return 0, saveFail(http.StatusNotFound, "Задача не найдена или нет прав доступа")
+        }
+        return op.ID, nil
+
+    case "delete":
+        result, err := tx.Exec(
+            taskSubtreeCTE+`
+            UPDATE tasks SET deleted_at = CURRENT_TIMESTAMP WHERE id IN (SELECT id FROM subtree)`,
+            op.ID, userID,
+        )
+        if err != nil {
+            return 0, err
+        }
+        if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+            return 0, saveFail(http.StatusNotFound, "Задача не найдена или нет прав доступа")
+        }
+        return op.ID, nil
+    }
+    return 0, saveFail(http.StatusBadRequest, "Неизвестная операция")
+}
+
+// bulkTaskCompletion проверяет отметку о выполнении задачи так же, как одиночные запросы,
+// и возвращает новый статус. Отказ становится результатом операции с кодом 409.
+func bulkTaskCompletion(tx *sql.Tx, taskID, userID int, completed, force bool) (string, error) {
+    current, err := taskStatus(tx, taskID, userID)
+    if err == sql.ErrNoRows {
+        return "", saveFail(http.StatusNotFound, "Задача не найдена или нет прав доступа")
+    } else if err != nil {
+        return "", err
+    }
+
+    status := completionStatus(current, completed)
+    return status, checkTaskTransition(tx, taskID, userID, current, status, "SELECT $1::int", force)
+}
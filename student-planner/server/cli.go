@@ -0,0 +1,429 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "text/tabwriter"
+    "time"
+)
+
+const cliUsage = `Использование: server [команда] [флаги]
+
+Команды:
+  serve                      запустить HTTP API (по умолчанию)
+  migrate                    создать таблицы и применить миграции
+  user create                создать пользователя (-email, -password, -name, -timezone, -role)
+  user list                  список пользователей (-q, -role, -locked)
+  user lock                  заблокировать учётную запись (-email, -unlock чтобы разблокировать)
+  user set-password          задать пароль (-email, -password; без -password генерируется временный)
+  user set-role              сменить роль (-email, -role: student, group_leader или admin)
+  stats                      сводка по пользователям, событиям, задачам и группам
+  seed-demo                  создать или сбросить демо-аккаунт test@example.com
+  export-user                выгрузить данные пользователя в JSON (-email, -o)
+  purge-trash                окончательно удалить старые записи из корзины (-days)
+
+Подключение к базе настраивается переменными DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME.
+`
+
+var errUsage = errors.New("неизвестная команда, см. server help")
+
+// runCommand выполняет подкоманду из args. Без аргументов запускается сервер.
+func runCommand(cfg Config, args []string) error {
+    if len(args) == 0 {
+        return serve(cfg)
+    }
+
+    switch args[0] {
+    case "serve":
+        return serve(cfg)
+    case "migrate":
+        return runMigrate(cfg)
+    case "user":
+        return runUser(cfg, args[1:])
+    case "seed-demo":
+        return runSeedDemo(cfg)
+    case "export-user":
+        return runExportUser(cfg, args[1:])
+    case "purge-trash":
+        return runPurgeTrash(cfg, args[1:])
+    case "stats":
+        return runStats(cfg)
+    case "help", "-h", "--help":
+        fmt.Print(cliUsage)
+        return nil
+    }
+
+    fmt.Fprint(os.Stderr, cliUsage)
+    return errUsage
+}
+
+// withDB подключается к базе на время подкоманды.
+func withDB(cfg Config, fn func() error) error {
+    conn, err := openDB(cfg)
+    if err != nil {
+        return fmt.Errorf("ошибка подключения к БД: %v", err)
+    }
+    defer conn.Close()
+    return fn()
+}
+
+// lookupUser находит пользователя по -email для подкоманд.
+func lookupUser(email string) (User, error) {
+    if email == "" {
+        return User{}, errors.New("укажите -email")
+    }
+    user, err := findUserByEmail(email)
+    if err == sql.ErrNoRows {
+        return User{}, fmt.Errorf("пользователь %s не найден", email)
+    }
+    return user, err
+}
+
+func runMigrate(cfg Config) error {
+    conn, err := InitDB(cfg)
+    if err != nil {
+        return fmt.Errorf("ошибка миграции БД: %v", err)
+    }
+    return conn.Close()
+}
+
+func runUser(cfg Config, args []string) error {
+    if len(args) == 0 {
+        fmt.Fprint(os.Stderr, cliUsage)
+        return errUsage
+    }
+
+    fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+    email := fs.String("email", "", "email пользователя")
+
+    switch args[0] {
+    case "create":
+        password := fs.String("password", "", "пароль")
+        name := fs.String("name", "", "имя")
+        timezone := fs.String("timezone", defaultTimezone, "часовой пояс IANA")
+        role := fs.String("role", roleStudent, "роль: student, group_leader или admin")
+        if err := fs.Parse(args[1:]); err != nil {
+            return err
+        }
+        if *email == "" || len(*password) < 6 {
+            return errors.New("укажите -email и -password не короче 6 символов")
+        }
+        if !userRoles[*role] {
+            return fmt.Errorf("неизвестная роль %q", *role)
+        }
+        if !validTimezone(*timezone) {
+            return fmt.Errorf("неизвестный часовой пояс %q", *timezone)
+        }
+
+        return withDB(cfg, func() error {
+            user, err := createUser(*email, *password, *name, *timezone, *role)
+            if err != nil {
+                return err
+            }
+            fmt.Printf("Создан пользователь %d: %s (%s)\n", user.ID, user.Email, user.Role)
+            return nil
+        })
+
+    case "list":
+        q := fs.String("q", "", "поиск по email и имени")
+        role := fs.String("role", "", "только пользователи с этой ролью")
+        locked := fs.Bool("locked", false, "только заблокированные")
+        if err := fs.Parse(args[1:]); err != nil {
+            return err
+        }
+
+        return withDB(cfg, func() error {
+            users, err := listUsers(*q, *role, *locked)
+            if err != nil {
+                return err
+            }
+
+            tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+            fmt.Fprintln(tw, "ID\tEMAIL\tИМЯ\tРОЛЬ\tСОБЫТИЯ\tЗАДАЧИ\tЗАБЛОКИРОВАН")
+            for _, user := range users {
+                locked := ""
+                if user.LockedAt != nil {
+                    locked = user.LockedAt.Format("2006-01-02 15:04")
+                }
+                fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n",
+                    user.ID, user.Email, user.Name, user.Role, user.Events, user.Tasks, locked)
+            }
+            return tw.Flush()
+        })
+
+    case "set-role":
+        role := fs.String("role", "", "роль: student, group_leader или admin")
+        if err := fs.Parse(args[1:]); err != nil {
+            return err
+        }
+        if !userRoles[*role] {
+            return fmt.Errorf("неизвестная роль %q", *role)
+        }
+
+        return withDB(cfg, func() error {
+            user, err := lookupUser(*email)
+            if err != nil {
+                return err
+            }
+            if _, err := updateUserRole(user.ID, *role); err != nil {
+                return err
+            }
+            fmt.Printf("Роль %s: %s\n", user.Email, *role)
+            return nil
+        })
+
+    case "lock":
+        unlock := fs.Bool("unlock", false, "разблокировать вместо блокировки")
+        if err := fs.Parse(args[1:]); err != nil {
+            return err
+        }
+
+        return withDB(cfg, func() error {
+            user, err := lookupUser(*email)
+            if err != nil {
+                return err
+            }
+            if _, err := updateUserLock(user.ID, !*unlock); err != nil {
+                return err
+            }
+            if *unlock {
+                fmt.Printf("Учётная запись %s разблокирована\n", user.Email)
+            } else {
+                fmt.Printf("Учётная запись %s заблокирована\n", user.Email)
+            }
+            return nil
+        })
+
+    case "set-password":
+        password := fs.String("password", "", "новый пароль; без него генерируется временный")
+        if err := fs.Parse(args[1:]); err != nil {
+            return err
+        }
+        if *password != "" && len(*password) < 6 {
+            return errors.New("пароль должен быть не короче 6 символов")
+        }
+
+        return withDB(cfg, func() error {
+            user, err := lookupUser(*email)
+            if err != nil {
+                return err
+            }
+
+            generated := *password == ""
+            if generated {
+                if *password, err = newTemporaryPassword(); err != nil {
+                    return err
+                }
+            }
+            if _, err := updateUserPassword(user.ID, *password); err != nil {
+                return err
+            }
+
+            if generated {
+                fmt.Printf("Временный пароль для %s: %s\n", user.Email, *password)
+            } else {
+                fmt.Printf("Пароль для %s изменён\n", user.Email)
+            }
+            return nil
+        })
+    }
+
+    fmt.Fprint(os.Stderr, cliUsage)
+    return errUsage
+}
+
+func runSeedDemo(cfg Config) error {
+    conn, err := InitDB(cfg)
+    if err != nil {
+        return fmt.Errorf("ошибка инициализации БД: %v", err)
+    }
+    defer conn.Close()
+
+    user, err := seedDemo(time.Now())
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Демо-аккаунт готов: %s / %s (id %d)\n", demoEmail, demoPassword, user.ID)
+    return nil
+}
+
+func runStats(cfg Config) error {
+    return withDB(cfg, func() error {
+        stats, err := loadSystemStats()
+        if err != nil {
+            return err
+        }
+
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintf(tw, "Пользователи\t%d (заблокировано %d, новых за неделю %d)\n", stats.Users, stats.LockedUsers, stats.NewUsersWeek)
+        for _, role := range []string{roleStudent, roleGroupLeader, roleAdmin} {
+            fmt.Fprintf(tw, "  %s\t%d\n", role, stats.UsersByRole[role])
+        }
+        fmt.Fprintf(tw, "События\t%d (в корзине %d)\n", stats.Events, stats.TrashedEvents)
+        fmt.Fprintf(tw, "Задачи\t%d (в корзине %d)\n", stats.Tasks, stats.TrashedTasks)
+        fmt.Fprintf(tw, "Группы\t%d\n", stats.Groups)
+        fmt.Fprintf(tw, "Общие доступы\t%d\n", stats.Shares)
+        fmt.Fprintf(tw, "Записи журнала\t%d\n", stats.HistoryRows)
+        return tw.Flush()
+    })
+}
+
+func runPurgeTrash(cfg Config, args []string) error {
+    fs := flag.NewFlagSet("purge-trash", flag.ContinueOnError)
+    days := fs.Int("days", int(cfg.TrashRetention/(24*time.Hour)), "удалить записи, пролежавшие в корзине дольше стольких дней")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if *days < 0 {
+        return errors.New("-days не может быть отрицательным")
+    }
+
+    return withDB(cfg, func() error {
+        events, tasks, err := purgeTrash(time.Duration(*days) * 24 * time.Hour)
+        if err != nil {
+            return err
+        }
+        fmt.Printf("Корзина очищена: событий %d, задач %d\n", events, tasks)
+        return nil
+    })
+}
+
+// UserExport — все данные пользователя для переноса или ответа на запрос о персональных данных.
+// Записи из корзины не выгружаются.
+type UserExport struct {
+    ExportedAt time.Time `json:"exported_at"`
+    User       User      `json:"user"`
+    Terms      []Term    `json:"terms"`
+    Subjects   []Subject `json:"subjects"`
+    Teachers   []Teacher `json:"teachers"`
+    Rooms      []Room    `json:"rooms"`
+    Tags       []Tag     `json:"tags"`
+    Events     []Event   `json:"events"`
+    Tasks      []Task    `json:"tasks"`
+    Grades     []Grade   `json:"grades"`
+}
+
+// exportRows выполняет запрос по user_id и передаёт каждую строку в scan.
+func exportRows(query string, userID int, scan func(row rowScanner) error) error {
+    rows, err := db.Query(query, userID)
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        if err := scan(rows); err != nil {
+            return err
+        }
+    }
+    return rows.Err()
+}
+
+func exportUser(user User) (UserExport, error) {
+    export := UserExport{
+        ExportedAt: time.Now().UTC(),
+        User:       user,
+        Terms:      []Term{},
+        Subjects:   []Subject{},
+        Teachers:   []Teacher{},
+        Rooms:      []Room{},
+        Tags:       []Tag{},
+        Events:     []Event{},
+        Tasks:      []Task{},
+        Grades:     []Grade{},
+    }
+    loc := userLocation(user.ID)
+
+    steps := []struct {
+        query string
+        scan  func(row rowScanner) error
+    }{
+        {`SELECT ` + termColumns + ` FROM terms WHERE user_id = $1 ORDER BY start_date`, func(row rowScanner) error {
+            term, err := scanTerm(row)
+            export.Terms = append(export.Terms, term)
+            return err
+        }},
+        {`SELECT ` + subjectColumns + ` FROM subjects WHERE user_id = $1 ORDER BY name`, func(row rowScanner) error {
+            subject, err := scanSubject(row)
+            export.Subjects = append(export.Subjects, subject)
+            return err
+        }},
+        {`SELECT ` + teacherColumns + ` FROM teachers WHERE user_id = $1 ORDER BY name`, func(row rowScanner) error {
+            teacher, err := scanTeacher(row)
+            export.Teachers = append(export.Teachers, teacher)
+            return err
+        }},
+        {`SELECT ` + roomColumns + ` FROM rooms WHERE user_id = $1 ORDER BY building, number`, func(row rowScanner) error {
+            room, err := scanRoom(row)
+            export.Rooms = append(export.Rooms, room)
+            return err
+        }},
+        {`SELECT ` + tagColumns + ` FROM tags WHERE user_id = $1 ORDER BY name`, func(row rowScanner) error {
+            tag, err := scanTag(row)
+            export.Tags = append(export.Tags, tag)
+            return err
+        }},
+        {`SELECT ` + eventColumns("$1") + ` FROM events WHERE user_id = $1 AND deleted_at IS NULL ORDER BY starts_at`, func(row rowScanner) error {
+            event, err := scanEvent(row, loc)
+            export.Events = append(export.Events, event)
+            return err
+        }},
+        {`SELECT ` + taskColumns + ` FROM tasks WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id`, func(row rowScanner) error {
+            task, err := scanTask(row)
+            export.Tasks = append(export.Tasks, task)
+            return err
+        }},
+        {`SELECT ` + gradeColumns + ` FROM grades WHERE user_id = $1 ORDER BY graded_on`, func(row rowScanner) error {
+            grade, err := scanGrade(row)
+            export.Grades = append(export.Grades, grade)
+            return err
+        }},
+    }
+
+    for _, step := range steps {
+        if err := exportRows(step.query, user.ID, step.scan); err != nil {
+            return export, err
+        }
+    }
+    return export, nil
+}
+
+func runExportUser(cfg Config, args []string) error {
+    fs := flag.NewFlagSet("export-user", flag.ContinueOnError)
+    email := fs.String("email", "", "email пользователя")
+    output := fs.String("o", "", "файл для выгрузки; по умолчанию stdout")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    return withDB(cfg, func() error {
+        user, err := lookupUser(*email)
+        if err != nil {
+            return err
+        }
+
+        export, err := exportUser(user)
+        if err != nil {
+            return fmt.Errorf("ошибка выгрузки: %v", err)
+        }
+
+        var out io.Writer = os.Stdout
+        if *output != "" {
+            file, err := os.Create(*output)
+            if err != nil {
+                return err
+            }
+            defer file.Close()
+            out = file
+        }
+
+        encoder := json.NewEncoder(out)
+        encoder.SetIndent("", "  ")
+        return encoder.Encode(export)
+    })
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Config — настройки сервера и подкоманд. Значения по умолчанию совпадают
+// с локальной установкой из README, переменные окружения их переопределяют.
+type Config struct {
+    DBHost         string
+    DBPort         string
+    DBUser         string
+    DBPassword     string
+    DBName         string
+    Port           string
+    TrashRetention time.Duration
+    AdminEmail     string
+    // DemoAccount — создавать ли демо-аккаунт при запуске, если его нет.
+    DemoAccount bool
+    // DemoResetInterval — как часто сбрасывать демо-аккаунт; 0 отключает сброс.
+    DemoResetInterval time.Duration
+    // RateLimitStore — где хранить лимиты входа и регистрации: memory или postgres.
+    RateLimitStore string
+    // TrustProxy разрешает брать адрес клиента из X-Forwarded-For.
+    TrustProxy bool
+}
+
+func envOr(key, fallback string) string {
+    if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+        return value
+    }
+    return fallback
+}
+
+func loadConfig() Config {
+    return Config{
+        DBHost:            envOr("DB_HOST", "localhost"),
+        DBPort:            envOr("DB_PORT", "5432"),
+        DBUser:            envOr("DB_USER", "postgres"),
+        DBPassword:        envOr("DB_PASSWORD", "admin"),
+        DBName:            envOr("DB_NAME", "student_planner"),
+        Port:              envOr("PORT", "8080"),
+        TrashRetention:    trashRetentionFromEnv(),
+        AdminEmail:        envOr("ADMIN_EMAIL", ""),
+        DemoAccount:       envOr("DEMO_ACCOUNT", "true") != "false",
+        DemoResetInterval: time.Duration(envInt("DEMO_RESET_HOURS", 0)) * time.Hour,
+        RateLimitStore:    envOr("RATE_LIMIT_STORE", "memory"),
+        TrustProxy:        envOr("TRUST_PROXY", "false") == "true",
+    }
+}
+
+func envInt(key string, fallback int) int {
+    value, err := strconv.Atoi(envOr(key, ""))
+    if err != nil || value < 0 {
+        return fallback
+    }
+    return value
+}
+
+func (c Config) connString() string {
+    return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+        c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName)
+}
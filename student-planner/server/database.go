@@ -5,28 +5,31 @@ import (
     "fmt"
     "log"
     
-    _ "github.com/lib/pq"
+    "github.com/lib/pq"
 )
 
 var db *sql.DB
 
-func InitDB() (*sql.DB, error) {
-    host := "localhost"
-    port := "5432"
-    user := "postgres"
-    password := "admin"
-    dbname := "student_planner"
-    
-    connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-        host, port, user, password, dbname)
-    
+// openDB подключается к базе без изменения схемы — этого достаточно подкомандам,
+// работающим с уже развёрнутой базой.
+func openDB(cfg Config) (*sql.DB, error) {
     var err error
-    db, err = sql.Open("postgres", connStr)
+    db, err = sql.Open("postgres", cfg.connString())
     if err != nil {
         return nil, err
     }
     
     if err = db.Ping(); err != nil {
+        db.Close()
+        return nil, err
+    }
+    return db, nil
+}
+
+// InitDB подключается к базе, создаёт недостающие таблицы и применяет миграции.
+func InitDB(cfg Config) (*sql.DB, error) {
+    conn, err := openDB(cfg)
+    if err != nil {
         return nil, err
     }
     
@@ -34,8 +37,12 @@ func InitDB() (*sql.DB, error) {
         return nil, err
     }
     
+    if err = migrateTables(); err != nil {
+        return nil, err
+    }
+    
     log.Println("База данных подключена")
-    return db, nil
+    return conn, nil
 }
 
 func createTables() error {
@@ -45,21 +52,110 @@ func createTables() error {
         email VARCHAR(255) UNIQUE NOT NULL,
         password VARCHAR(255) NOT NULL,
         name VARCHAR(255) NOT NULL,
+        timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+        role VARCHAR(20) NOT NULL DEFAULT 'student' CHECK (role IN ('student', 'group_leader', 'admin')),
+        locked_at TIMESTAMPTZ,
+        totp_secret VARCHAR(64),
+        totp_enabled_at TIMESTAMPTZ,
+        totp_last_counter BIGINT NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );`
+    
+    termsTable := `
+    CREATE TABLE IF NOT EXISTS terms (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        name VARCHAR(100) NOT NULL,
+        start_date DATE NOT NULL,
+        end_date DATE NOT NULL,
+        exam_start_date DATE,
+        exam_end_date DATE,
+        holidays JSONB NOT NULL DEFAULT '[]',
+        is_archived BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );`
+    
+    subjectsTable := `
+    CREATE TABLE IF NOT EXISTS subjects (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        name VARCHAR(100) NOT NULL,
+        teacher VARCHAR(255),
+        color VARCHAR(20),
+        credits DECIMAL(4,1) NOT NULL DEFAULT 0,
+        semester INTEGER,
+        min_attendance DECIMAL(5,2) NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (user_id, name)
+    );`
+    
+    teachersTable := `
+    CREATE TABLE IF NOT EXISTS teachers (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        name VARCHAR(255) NOT NULL,
+        email VARCHAR(255),
+        office_hours VARCHAR(255),
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );`
+    
+    roomsTable := `
+    CREATE TABLE IF NOT EXISTS rooms (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        building VARCHAR(100) NOT NULL DEFAULT '',
+        number VARCHAR(50) NOT NULL,
+        capacity INTEGER,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (user_id, building, number)
+    );`
+    
+    studyGroupsTable := `
+    CREATE TABLE IF NOT EXISTS study_groups (
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        invite_code VARCHAR(16) UNIQUE NOT NULL,
+        owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );`
     
+    groupMembersTable := `
+    CREATE TABLE IF NOT EXISTS group_members (
+        group_id INTEGER REFERENCES study_groups(id) ON DELETE CASCADE,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        role VARCHAR(20) NOT NULL DEFAULT 'viewer' CHECK (role IN ('owner', 'editor', 'viewer')),
+        joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (group_id, user_id)
+    );`
+    
+    groupInvitesTable := `
+    CREATE TABLE IF NOT EXISTS group_invites (
+        id SERIAL PRIMARY KEY,
+        group_id INTEGER REFERENCES study_groups(id) ON DELETE CASCADE,
+        email VARCHAR(255) NOT NULL,
+        role VARCHAR(20) NOT NULL DEFAULT 'viewer' CHECK (role IN ('editor', 'viewer')),
+        invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (group_id, email)
+    );`
+    
     eventsTable := `
     CREATE TABLE IF NOT EXISTS events (
         id SERIAL PRIMARY KEY,
         user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        group_id INTEGER REFERENCES study_groups(id) ON DELETE SET NULL,
         title VARCHAR(255) NOT NULL,
         description TEXT,
         event_type VARCHAR(50) NOT NULL,
-        subject VARCHAR(100),
+        subject_id INTEGER REFERENCES subjects(id) ON DELETE SET NULL,
         location VARCHAR(255),
-        event_date DATE NOT NULL,
-        start_time TIME NOT NULL,
+        teacher_id INTEGER REFERENCES teachers(id) ON DELETE SET NULL,
+        room_id INTEGER REFERENCES rooms(id) ON DELETE SET NULL,
+        starts_at TIMESTAMPTZ NOT NULL,
         duration_hours DECIMAL(3,1) NOT NULL,
+        deleted_at TIMESTAMPTZ,
+        version INTEGER NOT NULL DEFAULT 1,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );`
     
@@ -71,11 +167,167 @@ func createTables() error {
         description TEXT,
         priority VARCHAR(20) DEFAULT 'medium',
         is_completed BOOLEAN DEFAULT FALSE,
+        status VARCHAR(20) NOT NULL DEFAULT 'todo'
+            CHECK (status IN ('todo', 'in_progress', 'blocked', 'done', 'cancelled')),
+        started_at TIMESTAMPTZ,
+        completed_at TIMESTAMPTZ,
         due_date DATE,
+        due_at TIMESTAMPTZ,
+        subject_id INTEGER REFERENCES subjects(id) ON DELETE SET NULL,
+        parent_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE,
+        position INTEGER NOT NULL DEFAULT 0,
+        recurrence JSONB,
+        next_task_id INTEGER REFERENCES tasks(id) ON DELETE SET NULL,
+        deleted_at TIMESTAMPTZ,
+        version INTEGER NOT NULL DEFAULT 1,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );`
     
-    tables := []string{usersTable, eventsTable, tasksTable}
+    gradesTable := `
+    CREATE TABLE IF NOT EXISTS grades (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        subject_id INTEGER NOT NULL REFERENCES subjects(id) ON DELETE CASCADE,
+        event_id INTEGER REFERENCES events(id) ON DELETE SET NULL,
+        task_id INTEGER REFERENCES tasks(id) ON DELETE SET NULL,
+        name VARCHAR(255) NOT NULL,
+        weight DECIMAL(6,2) NOT NULL DEFAULT 1,
+        score DECIMAL(8,2) NOT NULL,
+        max_score DECIMAL(8,2) NOT NULL CHECK (max_score > 0),
+        graded_on DATE NOT NULL DEFAULT CURRENT_DATE,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );`
+    
+    attendanceTable := `
+    CREATE TABLE IF NOT EXISTS attendance (
+        event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        status VARCHAR(20) NOT NULL CHECK (status IN ('attended', 'missed', 'excused')),
+        note TEXT,
+        marked_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (event_id, user_id)
+    );`
+    
+    taskDependenciesTable := `
+    CREATE TABLE IF NOT EXISTS task_dependencies (
+        task_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE,
+        depends_on_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (task_id, depends_on_id),
+        CHECK (task_id <> depends_on_id)
+    );`
+    
+    tagsTable := `
+    CREATE TABLE IF NOT EXISTS tags (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        name VARCHAR(100) NOT NULL,
+        color VARCHAR(20),
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (user_id, name)
+    );`
+    
+    eventTagsTable := `
+    CREATE TABLE IF NOT EXISTS event_tags (
+        event_id INTEGER REFERENCES events(id) ON DELETE CASCADE,
+        tag_id INTEGER REFERENCES tags(id) ON DELETE CASCADE,
+        PRIMARY KEY (event_id, tag_id)
+    );`
+    
+    taskTagsTable := `
+    CREATE TABLE IF NOT EXISTS task_tags (
+        task_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE,
+        tag_id INTEGER REFERENCES tags(id) ON DELETE CASCADE,
+        PRIMARY KEY (task_id, tag_id)
+    );`
+    
+    // Участник группы может скрыть у себя её общее событие, например факультатив.
+    groupEventOverridesTable := `
+    CREATE TABLE IF NOT EXISTS group_event_overrides (
+        event_id INTEGER REFERENCES events(id) ON DELETE CASCADE,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        hidden BOOLEAN NOT NULL DEFAULT FALSE,
+        PRIMARY KEY (event_id, user_id)
+    );`
+    
+    // Доступ к отдельному событию или задаче другого пользователя.
+    itemSharesTable := `
+    CREATE TABLE IF NOT EXISTS item_shares (
+        entity_type VARCHAR(10) NOT NULL CHECK (entity_type IN ('event', 'task')),
+        entity_id INTEGER NOT NULL,
+        owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        permission VARCHAR(10) NOT NULL CHECK (permission IN ('read', 'edit')),
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (entity_type, entity_id, user_id)
+    );`
+    
+    // Журнал только дописывается; записи переживают окончательное удаление объекта.
+    changeHistoryTable := `
+    CREATE TABLE IF NOT EXISTS change_history (
+        id BIGSERIAL PRIMARY KEY,
+        entity_type VARCHAR(10) NOT NULL CHECK (entity_type IN ('event', 'task')),
+        entity_id INTEGER NOT NULL,
+        actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+        action VARCHAR(20) NOT NULL,
+        before JSONB,
+        after JSONB,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );`
+    
+    // Состояние ограничения частоты запросов, если RATE_LIMIT_STORE=postgres.
+    rateLimitBucketsTable := `
+    CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+        key VARCHAR(320) PRIMARY KEY,
+        tokens DOUBLE PRECISION NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL
+    );`
+    
+    loginFailuresTable := `
+    CREATE TABLE IF NOT EXISTS login_failures (
+        key VARCHAR(320) PRIMARY KEY,
+        failures INTEGER NOT NULL DEFAULT 0,
+        last_failure_at TIMESTAMPTZ NOT NULL,
+        locked_until TIMESTAMPTZ
+    );`
+    
+    // Резервные коды 2FA хранятся только в виде bcrypt-хешей.
+    totpRecoveryCodesTable := `
+    CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        code_hash VARCHAR(255) NOT NULL,
+        used_at TIMESTAMPTZ,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );`
+    
+    // Второй шаг входа с 2FA: в базе только sha256 от выданного токена.
+    loginChallengesTable := `
+    CREATE TABLE IF NOT EXISTS login_challenges (
+        token_hash CHAR(64) PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        expires_at TIMESTAMPTZ NOT NULL
+    );`
+    
+    // Сессии входа: клиент передаёт токен в Authorization, в базе только его sha256.
+    sessionsTable := `
+    CREATE TABLE IF NOT EXISTS sessions (
+        token_hash CHAR(64) PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMPTZ NOT NULL
+    );`
+    
+    tables := []string{
+        usersTable, termsTable, subjectsTable, teachersTable, roomsTable,
+        studyGroupsTable, groupMembersTable, groupInvitesTable,
+        eventsTable, tasksTable, gradesTable, attendanceTable, taskDependenciesTable,
+        tagsTable, eventTagsTable, taskTagsTable, groupEventOverridesTable, itemSharesTable,
+        changeHistoryTable, rateLimitBucketsTable, loginFailuresTable,
+        totpRecoveryCodesTable, loginChallengesTable, sessionsTable,
+    }
     
     for _, table := range tables {
         if _, err := db.Exec(table); err != nil {
@@ -85,4 +337,166 @@ func createTables() error {
     
     log.Println("Таблицы созданы/проверены")
     return nil
+}
+
+// migrations приводят схему уже существующих баз к текущей.
+// Каждая миграция должна быть идемпотентной: они выполняются при каждом запуске.
+var migrations = []string{
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'`,
+
+    // event_date + start_time хранились как локальное время без пояса;
+    // переносим их в starts_at, считая время указанным в поясе владельца.
+    `DO $$
+    BEGIN
+        IF EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'events' AND column_name = 'event_date') THEN
+            ALTER TABLE events ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;
+            UPDATE events e
+               SET starts_at = (e.event_date + e.start_time) AT TIME ZONE COALESCE(u.timezone, 'UTC')
+              FROM users u
+             WHERE u.id = e.user_id AND e.starts_at IS NULL;
+            UPDATE events SET starts_at = (event_date + start_time) AT TIME ZONE 'UTC'
+             WHERE starts_at IS NULL;
+            ALTER TABLE events ALTER COLUMN starts_at SET NOT NULL;
+            ALTER TABLE events DROP COLUMN event_date, DROP COLUMN start_time;
+        END IF;
+    END $$`,
+
+    `CREATE INDEX IF NOT EXISTS events_user_starts_at_idx ON events (user_id, starts_at)`,
+
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS subject_id INTEGER REFERENCES subjects(id) ON DELETE SET NULL`,
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS subject_id INTEGER REFERENCES subjects(id) ON DELETE SET NULL`,
+
+    // Свободный текст events.subject превращается в записи subjects.
+    `DO $$
+    BEGIN
+        IF EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'events' AND column_name = 'subject') THEN
+            INSERT INTO subjects (user_id, name)
+            SELECT DISTINCT user_id, TRIM(subject) FROM events
+             WHERE user_id IS NOT NULL AND TRIM(COALESCE(subject, '')) <> ''
+            ON CONFLICT (user_id, name) DO NOTHING;
+            UPDATE events e
+               SET subject_id = s.id
+              FROM subjects s
+             WHERE s.user_id = e.user_id AND s.name = TRIM(e.subject) AND e.subject_id IS NULL;
+            ALTER TABLE events DROP COLUMN subject;
+        END IF;
+    END $$`,
+
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS teacher_id INTEGER REFERENCES teachers(id) ON DELETE SET NULL`,
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS room_id INTEGER REFERENCES rooms(id) ON DELETE SET NULL`,
+    `CREATE INDEX IF NOT EXISTS events_room_starts_at_idx ON events (room_id, starts_at)`,
+
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS active_term_id INTEGER REFERENCES terms(id) ON DELETE SET NULL`,
+
+    `ALTER TABLE subjects ADD COLUMN IF NOT EXISTS min_attendance DECIMAL(5,2) NOT NULL DEFAULT 0`,
+
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE`,
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0`,
+    `CREATE INDEX IF NOT EXISTS tasks_parent_id_idx ON tasks (parent_id)`,
+
+    // Булев is_completed превращается в статус; выполненным задачам время
+    // завершения неизвестно, поэтому берём время создания.
+    `DO $$
+    BEGIN
+        IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                       WHERE table_name = 'tasks' AND column_name = 'status') THEN
+            ALTER TABLE tasks
+                ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'todo'
+                    CHECK (status IN ('todo', 'in_progress', 'blocked', 'done', 'cancelled')),
+                ADD COLUMN started_at TIMESTAMPTZ,
+                ADD COLUMN completed_at TIMESTAMPTZ;
+            UPDATE tasks SET status = 'done', completed_at = created_at WHERE is_completed;
+        END IF;
+    END $$`,
+
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence JSONB`,
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS next_task_id INTEGER REFERENCES tasks(id) ON DELETE SET NULL`,
+
+    // due_at — точный дедлайн; due_date остаётся локальной датой срока для календаря и фильтров.
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ`,
+
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+    `CREATE INDEX IF NOT EXISTS events_deleted_at_idx ON events (deleted_at) WHERE deleted_at IS NOT NULL`,
+    `CREATE INDEX IF NOT EXISTS tasks_deleted_at_idx ON tasks (deleted_at) WHERE deleted_at IS NOT NULL`,
+
+    `CREATE INDEX IF NOT EXISTS change_history_entity_idx ON change_history (entity_type, entity_id, id)`,
+
+    // version и updated_at меняются триггером при любом UPDATE, чтобы ETag
+    // не зависел от того, какой обработчик изменил строку.
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP`,
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
+    `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP`,
+    `CREATE OR REPLACE FUNCTION bump_row_version() RETURNS trigger AS $$
+    BEGIN
+        NEW.version := OLD.version + 1;
+        NEW.updated_at := CURRENT_TIMESTAMP;
+        RETURN NEW;
+    END $$ LANGUAGE plpgsql`,
+    `DROP TRIGGER IF EXISTS events_bump_version ON events`,
+    `CREATE TRIGGER events_bump_version BEFORE UPDATE ON events
+        FOR EACH ROW EXECUTE FUNCTION bump_row_version()`,
+    `DROP TRIGGER IF EXISTS tasks_bump_version ON tasks`,
+    `CREATE TRIGGER tasks_bump_version BEFORE UPDATE ON tasks
+        FOR EACH ROW EXECUTE FUNCTION bump_row_version()`,
+
+    `ALTER TABLE events ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES study_groups(id) ON DELETE SET NULL`,
+    `CREATE INDEX IF NOT EXISTS events_group_id_idx ON events (group_id) WHERE group_id IS NOT NULL`,
+    `CREATE INDEX IF NOT EXISTS group_members_user_id_idx ON group_members (user_id)`,
+
+    `CREATE INDEX IF NOT EXISTS item_shares_user_id_idx ON item_shares (user_id, entity_type)`,
+
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'student'
+        CHECK (role IN ('student', 'group_leader', 'admin'))`,
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_at TIMESTAMPTZ`,
+
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64)`,
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMPTZ`,
+    `ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_counter BIGINT NOT NULL DEFAULT 0`,
+    `CREATE INDEX IF NOT EXISTS totp_recovery_codes_user_id_idx ON totp_recovery_codes (user_id)`,
+
+    // Посещаемость стала личной: участники группы отмечают общее занятие каждый за себя,
+    // поэтому ключ event_id заменяется на (event_id, user_id).
+    `DO $$
+    BEGIN
+        IF (SELECT COUNT(*) FROM information_schema.key_column_usage
+            WHERE table_name = 'attendance' AND constraint_name = 'attendance_pkey') = 1 THEN
+            DELETE FROM attendance WHERE user_id IS NULL;
+            ALTER TABLE attendance ALTER COLUMN user_id SET NOT NULL;
+            ALTER TABLE attendance DROP CONSTRAINT attendance_pkey;
+            ALTER TABLE attendance ADD PRIMARY KEY (event_id, user_id);
+        END IF;
+    END $$`,
+
+    `CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id)`,
+
+    // Email уникален без учёта регистра. Если в старой базе уже есть адреса, отличающиеся
+    // только регистром, индекс не создаётся, пока их не объединят вручную.
+    `DO $$
+    BEGIN
+        IF NOT EXISTS (SELECT 1 FROM users GROUP BY LOWER(email) HAVING COUNT(*) > 1) THEN
+            CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (LOWER(email));
+        END IF;
+    END $$`,
+}
+
+func migrateTables() error {
+    for _, migration := range migrations {
+        if _, err := db.Exec(migration); err != nil {
+            return fmt.Errorf("ошибка миграции: %v", err)
+        }
+    }
+    
+    log.Println("Миграции применены")
+    return nil
+}
+
+// isUniqueViolation сообщает, что запись нарушила ограничение UNIQUE:
+// UPDATE не умеет ON CONFLICT, поэтому дубликат распознаётся по коду ошибки.
+func isUniqueViolation(err error) bool {
+    pqErr, ok := err.(*pq.Error)
+    return ok && pqErr.Code.Name() == "unique_violation"
 }
\ No newline at end of file
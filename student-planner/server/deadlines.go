@@ -0,0 +1,68 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+var errBadTaskDue = errors.New("неверный срок задачи")
+
+// taskOwnerTimezone — часовой пояс владельца задачи для выражений над tasks.
+const taskOwnerTimezone = `(SELECT timezone FROM users WHERE users.id = tasks.user_id)`
+
+// taskDeadlineColumn — момент дедлайна: явное время due_at или конец дня due_date
+// в поясе владельца. Задача без срока дедлайна не имеет.
+const taskDeadlineColumn = `COALESCE(due_at, (due_date + TIME '23:59:59') AT TIME ZONE ` + taskOwnerTimezone + `)`
+
+const taskDueTimeColumn = `COALESCE(to_char(due_at AT TIME ZONE ` + taskOwnerTimezone + `, 'HH24:MI'), '')`
+
+// taskOverdueCondition отбирает незавершённые задачи с прошедшим дедлайном.
+const taskOverdueCondition = `status NOT IN ('done', 'cancelled') AND ` + taskDeadlineColumn + ` < CURRENT_TIMESTAMP`
+
+// parseTaskDue разбирает срок задачи: due_at (RFC3339) или due_date + due_time в поясе
+// пользователя. Без времени срок действует весь день и due_at остаётся пустым.
+func parseTaskDue(dueAt, dueDate, dueTime string, loc *time.Location) (string, *time.Time, error) {
+    if dueAt == "" && dueTime == "" {
+        if dueDate == "" {
+            return "", nil, nil
+        }
+        if _, err := time.Parse("2006-01-02", dueDate); err != nil {
+            return "", nil, errBadTaskDue
+        }
+        return dueDate, nil, nil
+    }
+
+    at, err := parseEventStart(dueAt, dueDate, dueTime, loc)
+    if err != nil {
+        return "", nil, errBadTaskDue
+    }
+    return at.In(loc).Format("2006-01-02"), &at, nil
+}
+
+// applyDeadline считает, сколько часов осталось до дедлайна и просрочена ли задача.
+func applyDeadline(task *Task, now time.Time) {
+    if task.Deadline == nil {
+        return
+    }
+    hours := roundTo(task.Deadline.Sub(now).Hours(), 1)
+    task.HoursLeft = &hours
+    task.IsOverdue = task.Deadline.Before(now) && task.Status != "done" && task.Status != "cancelled"
+}
+
+// appendDeadlineFilter применяет ?overdue=true и ?due_within_hours=N списков задач.
+func appendDeadlineFilter(query string, args []interface{}, r *http.Request) (string, []interface{}) {
+    if r.URL.Query().Get("overdue") == "true" {
+        query += " AND " + taskOverdueCondition
+    }
+    if hours, err := strconv.Atoi(r.URL.Query().Get("due_within_hours")); err == nil && hours > 0 {
+        args = append(args, hours)
+        query += fmt.Sprintf(
+            " AND status NOT IN ('done', 'cancelled') AND %s BETWEEN CURRENT_TIMESTAMP AND CURRENT_TIMESTAMP + make_interval(hours => $%d)",
+            taskDeadlineColumn, len(args),
+        )
+    }
+    return query, args
+}
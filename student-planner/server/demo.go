@@ -0,0 +1,350 @@
+package main
+
+import (
+    "database/sql"
+    "log"
+    "time"
+)
+
+// Демо-аккаунт, который /api/demo предлагает для входа.
+const (
+    demoEmail    = "test@example.com"
+    demoPassword = "test123"
+    demoName     = "Демо Студент"
+    demoTimezone = "Europe/Moscow"
+)
+
+type demoSubject struct {
+    name, teacher, color string
+    credits              float64
+}
+
+var demoSubjects = []demoSubject{
+    {"Математический анализ", "Иванов Игорь Петрович", "#e74c3c", 5},
+    {"Программирование на Go", "Петрова Анна Сергеевна", "#3498db", 4},
+    {"Базы данных", "Сидоров Павел Викторович", "#27ae60", 4},
+    {"Английский язык", "Smith John", "#f39c12", 2},
+}
+
+type demoRoom struct {
+    building, number string
+    capacity         int
+}
+
+var demoRooms = []demoRoom{
+    {"Главный корпус", "101", 120},
+    {"Главный корпус", "214", 30},
+    {"Лабораторный корпус", "305", 20},
+}
+
+// demoLesson — занятие демо-недели; day считается от понедельника.
+type demoLesson struct {
+    day       int
+    clock     string
+    eventType string
+    subject   int
+    room      int
+    title     string
+}
+
+var demoWeek = []demoLesson{
+    {0, "09:00", "lecture", 0, 0, "Лекция: Пределы и непрерывность"},
+    {0, "10:45", "practice", 1, 2, "Практика: Горутины и каналы"},
+    {1, "09:00", "lecture", 2, 0, "Лекция: Нормальные формы"},
+    {1, "12:30", "practice", 3, 1, "Практика: Present Perfect"},
+    {2, "10:45", "lecture", 1, 0, "Лекция: Интерфейсы в Go"},
+    {2, "12:30", "practice", 0, 1, "Практика: Производные"},
+    {3, "09:00", "practice", 2, 2, "Практика: Индексы и планы запросов"},
+    {3, "14:00", "lecture", 0, 0, "Лекция: Числовые ряды"},
+    {4, "10:45", "practice", 1, 2, "Практика: HTTP-сервер на net/http"},
+}
+
+// demoTask — задача демо-аккаунта; dueDays отсчитываются от сегодняшнего дня, -1 значит без срока.
+type demoTask struct {
+    title, description, priority, status string
+    subject, dueDays                     int
+    subtasks                             []string
+}
+
+var demoTasks = []demoTask{
+    {"Лабораторная работа №3: REST API", "CRUD для заметок, тесты и README", "high", "in_progress", 1, 2,
+        []string{"Спроектировать маршруты", "Написать обработчики", "Покрыть тестами"}},
+    {"Домашнее задание: ряды", "Задачи 4.12–4.20 из задачника", "medium", "todo", 0, 3, nil},
+    {"Прочитать главу 5 учебника", "Транзакции и уровни изоляции", "low", "todo", 2, 4, nil},
+    {"Эссе: My future profession", "250–300 слов", "medium", "todo", 3, 6, nil},
+    {"Отчёт по практике", "", "medium", "done", 1, -1, nil},
+    {"Купить тетради", "", "low", "todo", -1, -1, nil},
+}
+
+// demoWeekStart — понедельник текущей учебной недели; в выходные показываем следующую.
+func demoWeekStart(now time.Time) time.Time {
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+    switch today.Weekday() {
+    case time.Saturday:
+        return today.AddDate(0, 0, 2)
+    case time.Sunday:
+        return today.AddDate(0, 0, 1)
+    }
+    return today.AddDate(0, 0, -int(today.Weekday()-time.Monday))
+}
+
+// seedDemo создаёт демо-аккаунт, если его ещё нет, и заново заполняет его данными
+// на неделю вокруг now. Пароль и блокировка сбрасываются, чтобы вход всегда работал.
+func seedDemo(now time.Time) (User, error) {
+    user, err := findUserByEmail(demoEmail)
+    if err == sql.ErrNoRows {
+        user, err = createUser(demoEmail, demoPassword, demoName, demoTimezone, roleStudent)
+    } else if err == nil {
+        _, err = updateUserPassword(user.ID, demoPassword)
+    }
+    if err != nil {
+        return user, err
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        return user, err
+    }
+    defer tx.Rollback()
+
+    if err := clearDemoData(tx, user.ID); err != nil {
+        return user, err
+    }
+    if err := fillDemoData(tx, user.ID, now); err != nil {
+        return user, err
+    }
+    return user, tx.Commit()
+}
+
+// clearDemoData удаляет всё, что успели создать посетители демо-аккаунта.
+func clearDemoData(tx *sql.Tx, userID int) error {
+    statements := []string{
+        `UPDATE users SET name = '` + demoName + `', timezone = '` + demoTimezone + `', role = 'student',
+                locked_at = NULL, active_term_id = NULL
+         WHERE id = $1`,
+        `DELETE FROM change_history
+         WHERE actor_id = $1
+            OR (entity_type = 'event' AND entity_id IN (SELECT id FROM events WHERE user_id = $1))
+            OR (entity_type = 'task' AND entity_id IN (SELECT id FROM tasks WHERE user_id = $1))`,
+        `DELETE FROM item_shares WHERE owner_id = $1 OR user_id = $1`,
+        `DELETE FROM study_groups WHERE owner_id = $1`,
+        `DELETE FROM group_members WHERE user_id = $1`,
+        `DELETE FROM group_invites WHERE LOWER(email) = (SELECT LOWER(email) FROM users WHERE id = $1)`,
+        `DELETE FROM attendance WHERE user_id = $1`,
+        `DELETE FROM grades WHERE user_id = $1`,
+        `DELETE FROM tasks WHERE user_id = $1`,
+        `DELETE FROM events WHERE user_id = $1`,
+        `DELETE FROM tags WHERE user_id = $1`,
+        `DELETE FROM subjects WHERE user_id = $1`,
+        `DELETE FROM teachers WHERE user_id = $1`,
+        `DELETE FROM rooms WHERE user_id = $1`,
+        `DELETE FROM terms WHERE user_id = $1`,
+    }
+    for _, statement := range statements {
+        if _, err := tx.Exec(statement, userID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func fillDemoData(tx *sql.Tx, userID int, now time.Time) error {
+    loc, err := time.LoadLocation(demoTimezone)
+    if err != nil {
+        return err
+    }
+    now = now.In(loc)
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+    weekStart := demoWeekStart(now)
+
+    var termID int
+    err = tx.QueryRow(
+        `INSERT INTO terms (user_id, name, start_date, end_date, exam_start_date, exam_end_date)
+         VALUES ($1, 'Текущий семестр', $2, $3, $4, $3) RETURNING id`,
+        userID, today.AddDate(0, -2, 0).Format("2006-01-02"), today.AddDate(0, 2, 0).Format("2006-01-02"),
+        today.AddDate(0, 1, 0).Format("2006-01-02"),
+    ).Scan(&termID)
+    if err != nil {
+        return err
+    }
+    if _, err := tx.Exec("UPDATE users SET active_term_id = $1 WHERE id = $2", termID, userID); err != nil {
+        return err
+    }
+
+    subjectIDs := make([]int, len(demoSubjects))
+    teacherIDs := make([]int, len(demoSubjects))
+    for i, subject := range demoSubjects {
+        err := tx.QueryRow(
+            `INSERT INTO subjects (user_id, name, teacher, color, credits, min_attendance)
+             VALUES ($1, $2, $3, $4, $5, 70) RETURNING id`,
+            userID, subject.name, subject.teacher, subject.color, subject.credits,
+        ).Scan(&subjectIDs[i])
+        if err != nil {
+            return err
+        }
+
+        err = tx.QueryRow(
+            "INSERT INTO teachers (user_id, name, office_hours) VALUES ($1, $2, 'Чт 15:00–16:30') RETURNING id",
+            userID, subject.teacher,
+        ).Scan(&teacherIDs[i])
+        if err != nil {
+            return err
+        }
+    }
+
+    roomIDs := make([]int, len(demoRooms))
+    for i, room := range demoRooms {
+        err := tx.QueryRow(
+            "INSERT INTO rooms (user_id, building, number, capacity) VALUES ($1, $2, $3, $4) RETURNING id",
+            userID, room.building, room.number, room.capacity,
+        ).Scan(&roomIDs[i])
+        if err != nil {
+            return err
+        }
+    }
+
+    insertEvent := func(title, eventType string, subject, room int, startsAt time.Time, hours float64) (int, error) {
+        var eventID int
+        location := demoRooms[room].building + ", ауд. " + demoRooms[room].number
+        err := tx.QueryRow(
+            `INSERT INTO events (user_id, title, event_type, subject_id, location, teacher_id, room_id, starts_at, duration_hours)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+            userID, title, eventType, subjectIDs[subject], location, teacherIDs[subject], roomIDs[room], startsAt, hours,
+        ).Scan(&eventID)
+        return eventID, err
+    }
+
+    for i, lesson := range demoWeek {
+        clock, _ := time.Parse("15:04", lesson.clock)
+        day := weekStart.AddDate(0, 0, lesson.day)
+        startsAt := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+
+        eventID, err := insertEvent(lesson.title, lesson.eventType, lesson.subject, lesson.room, startsAt, 1.5)
+        if err != nil {
+            return err
+        }
+
+        // Прошедшие занятия уже отмечены; один пропуск — чтобы было что показать в статистике.
+        if startsAt.Before(now) {
+            status := "attended"
+            if i == 1 {
+                status = "missed"
+            }
+            if _, err := tx.Exec(
+                "INSERT INTO attendance (event_id, user_id, status) VALUES ($1, $2, $3)",
+                eventID, userID, status,
+            ); err != nil {
+                return err
+            }
+        }
+    }
+
+    examDay := today.AddDate(0, 0, 5)
+    for examDay.Weekday() == time.Saturday || examDay.Weekday() == time.Sunday {
+        examDay = examDay.AddDate(0, 0, 1)
+    }
+    examAt := time.Date(examDay.Year(), examDay.Month(), examDay.Day(), 10, 0, 0, 0, loc)
+    if _, err := insertEvent("Экзамен: Базы данных", "exam", 2, 1, examAt, 3); err != nil {
+        return err
+    }
+
+    tasks := append([]demoTask{{
+        "Подготовиться к экзамену по базам данных", "Билеты выложены на портале", "high", "in_progress", 2,
+        int(examDay.Sub(today).Hours()/24) - 1,
+        []string{"Повторить нормальные формы", "Решить билеты 1–15", "Разобрать транзакции и индексы"},
+    }}, demoTasks...)
+
+    for _, task := range tasks {
+        var subjectID interface{}
+        if task.subject >= 0 {
+            subjectID = subjectIDs[task.subject]
+        }
+        var dueDate interface{}
+        if task.dueDays >= 0 {
+            dueDate = today.AddDate(0, 0, task.dueDays).Format("2006-01-02")
+        }
+        done := task.status == "done"
+
+        var taskID int
+        err := tx.QueryRow(
+            `INSERT INTO tasks (user_id, title, description, priority, status, is_completed, started_at, completed_at, due_date, subject_id)
+             VALUES ($1, $2, $3, $4, $5, $6,
+                     CASE WHEN $5 <> 'todo' THEN $7::timestamptz END,
+                     CASE WHEN $6 THEN $7::timestamptz END,
+                     $8, $9)
+             RETURNING id`,
+            userID, task.title, task.description, task.priority, task.status, done,
+            now.AddDate(0, 0, -1), dueDate, subjectID,
+        ).Scan(&taskID)
+        if err != nil {
+            return err
+        }
+
+        for position, title := range task.subtasks {
+            // Первая подзадача уже сделана, чтобы у родителя был виден прогресс.
+            subtaskDone := position == 0
+            status := "todo"
+            if subtaskDone {
+                status = "done"
+            }
+            if _, err := tx.Exec(
+                `INSERT INTO tasks (user_id, title, priority, status, is_completed, completed_at, subject_id, parent_id, position)
+                 VALUES ($1, $2, $3, $4, $5, CASE WHEN $5 THEN $6::timestamptz END, $7, $8, $9)`,
+                userID, title, task.priority, status, subtaskDone, now, subjectID, taskID, position,
+            ); err != nil {
+                return err
+            }
+        }
+    }
+
+    grades := []struct {
+        subject    int
+        name       string
+        score, max float64
+        daysAgo    int
+    }{
+        {0, "Контрольная работа №1", 17, 20, 14},
+        {1, "Лабораторная работа №2", 9, 10, 7},
+        {3, "Тест по грамматике", 42, 50, 3},
+    }
+    for _, grade := range grades {
+        if _, err := tx.Exec(
+            `INSERT INTO grades (user_id, subject_id, name, score, max_score, graded_on)
+             VALUES ($1, $2, $3, $4, $5, $6)`,
+            userID, subjectIDs[grade.subject], grade.name, grade.score, grade.max,
+            today.AddDate(0, 0, -grade.daysAgo).Format("2006-01-02"),
+        ); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ensureDemoAccount создаёт демо-аккаунт на свежей установке, не трогая уже существующий.
+func ensureDemoAccount() {
+    if _, err := findUserByEmail(demoEmail); err != sql.ErrNoRows {
+        return
+    }
+    if _, err := seedDemo(time.Now()); err != nil {
+        log.Printf("Ошибка создания демо-аккаунта: %v", err)
+        return
+    }
+    log.Printf("Создан демо-аккаунт %s", demoEmail)
+}
+
+// startDemoReset периодически возвращает демо-аккаунт в исходное состояние,
+// чтобы публичное демо оставалось чистым и неделя сдвигалась вместе с текущей датой.
+func startDemoReset(interval time.Duration) {
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for range ticker.C {
+            if _, err := seedDemo(time.Now()); err != nil {
+                log.Printf("Ошибка сброса демо-аккаунта: %v", err)
+            } else {
+                log.Printf("Демо-аккаунт %s сброшен", demoEmail)
+            }
+        }
+    }()
+}
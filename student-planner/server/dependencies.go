@@ -0,0 +1,273 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+
+    "github.com/gorilla/mux"
+    "github.com/lib/pq"
+)
+
+// dependsOnColumn и blockedByColumn подставляют в выборку задач все предпосылки
+// и ещё не выполненные предпосылки соответственно. Отменённая предпосылка не блокирует.
+const dependsOnColumn = `ARRAY(SELECT d.depends_on_id FROM task_dependencies d
+                JOIN tasks p ON p.id = d.depends_on_id
+                WHERE d.task_id = tasks.id AND p.deleted_at IS NULL ORDER BY d.depends_on_id)`
+
+const blockedByColumn = `ARRAY(SELECT d.depends_on_id FROM task_dependencies d
+                JOIN tasks p ON p.id = d.depends_on_id
+                WHERE d.task_id = tasks.id AND p.status NOT IN ('done', 'cancelled') AND p.deleted_at IS NULL
+                ORDER BY d.depends_on_id)`
+
+func intsFromArray(values pq.Int64Array) []int {
+    ints := make([]int, 0, len(values))
+    for _, v := range values {
+        ints = append(ints, int(v))
+    }
+    return ints
+}
+
+// openPrerequisites возвращает невыполненные предпосылки задач из scope,
+// не входящих в сам scope. scope — подзапрос, использующий $1 и $2 из taskSubtreeCTE.
+func openPrerequisites(q sqlQueryer, taskID, userID int, scope string) ([]int, error) {
+    rows, err := q.Query(
+        taskSubtreeCTE+`
+        SELECT DISTINCT d.depends_on_id
+        FROM task_dependencies d JOIN tasks p ON p.id = d.depends_on_id
+        WHERE d.task_id IN (`+scope+`)
+          AND d.depends_on_id NOT IN (`+scope+`)
+          AND p.status NOT IN ('done', 'cancelled') AND p.deleted_at IS NULL
+        ORDER BY d.depends_on_id`,
+        taskID, userID,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    ids := []int{}
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err == nil {
+            ids = append(ids, id)
+        }
+    }
+    return ids, nil
+}
+
+// writeBlockedBy отвечает 409 со списком невыполненных предпосылок.
+func writeBlockedBy(w http.ResponseWriter, blockedBy []int) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusConflict)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "error":      "Сначала нужно выполнить задачи, от которых зависит эта",
+        "blocked_by": blockedBy,
+    })
+}
+
+// dependencyLockClass — первый ключ advisory-блокировки графа зависимостей пользователя;
+// второй ключ — id владельца задач.
+const dependencyLockClass = 34
+
+// lockDependencyGraph до конца транзакции сериализует изменения зависимостей пользователя:
+// две одновременные связи, каждая из которых в одиночку цикла не даёт, не пройдут проверку
+// на одном и том же снимке графа.
+func lockDependencyGraph(tx sqlExecer, ownerID int) error {
+    _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", dependencyLockClass, ownerID)
+    return err
+}
+
+// createsDependencyCycle проверяет, достижима ли задача taskID из dependsOnID по
+// существующим связям: тогда новая связь taskID -> dependsOnID замкнёт цикл.
+func createsDependencyCycle(q sqlQueryer, taskID, dependsOnID int) (bool, error) {
+    var cycle bool
+    err := q.QueryRow(
+        `WITH RECURSIVE chain AS (
+            SELECT depends_on_id FROM task_dependencies WHERE task_id = $2
+            UNION
+            SELECT d.depends_on_id FROM task_dependencies d JOIN chain ON d.task_id = chain.depends_on_id
+        )
+        SELECT EXISTS (SELECT 1 FROM chain WHERE depends_on_id = $1)`,
+        taskID, dependsOnID,
+    ).Scan(&cycle)
+    return cycle, err
+}
+
+func GetTaskDependencies(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+
+    var exists bool
+    db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)", taskID, userID).Scan(&exists)
+    if !exists {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    loadTasks := func(query string) ([]Task, error) {
+        rows, err := db.Query(query, taskID, userID)
+        if err != nil {
+            return nil, err
+        }
+        defer rows.Close()
+
+        tasks := []Task{}
+        for rows.Next() {
+            task, err := scanTask(rows)
+            if err != nil {
+                continue
+            }
+            tasks = append(tasks, task)
+        }
+        return tasks, nil
+    }
+
+    dependsOn, err := loadTasks(
+        `SELECT ` + taskColumns + ` FROM tasks
+         WHERE user_id = $2 AND deleted_at IS NULL AND id IN (SELECT depends_on_id FROM task_dependencies WHERE task_id = $1)
+         ORDER BY id`,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения зависимостей"}`, http.StatusInternalServerError)
+        return
+    }
+
+    dependents, err := loadTasks(
+        `SELECT ` + taskColumns + ` FROM tasks
+         WHERE user_id = $2 AND deleted_at IS NULL AND id IN (SELECT task_id FROM task_dependencies WHERE depends_on_id = $1)
+         ORDER BY id`,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения зависимостей"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "depends_on": dependsOn,
+        "dependents": dependents,
+    })
+}
+
+func AddTaskDependency(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        DependsOnID int `json:"depends_on_id"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if req.DependsOnID == taskID {
+        http.Error(w, `{"error": "Задача не может зависеть от самой себя"}`, http.StatusBadRequest)
+        return
+    }
+
+    var count int
+    db.QueryRow(
+        "SELECT COUNT(*) FROM tasks WHERE id IN ($1, $2) AND user_id = $3 AND deleted_at IS NULL",
+        taskID, req.DependsOnID, userID,
+    ).Scan(&count)
+    if count != 2 {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка добавления зависимости"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    if err := lockDependencyGraph(tx, userID); err != nil {
+        http.Error(w, `{"error": "Ошибка добавления зависимости"}`, http.StatusInternalServerError)
+        return
+    }
+
+    cycle, err := createsDependencyCycle(tx, taskID, req.DependsOnID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки зависимостей"}`, http.StatusInternalServerError)
+        return
+    }
+    if cycle {
+        http.Error(w, `{"error": "Зависимость создаёт цикл"}`, http.StatusConflict)
+        return
+    }
+
+    _, err = tx.Exec(
+        `INSERT INTO task_dependencies (task_id, depends_on_id) VALUES ($1, $2)
+         ON CONFLICT DO NOTHING`,
+        taskID, req.DependsOnID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка добавления зависимости"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка добавления зависимости"}`, http.StatusInternalServerError)
+        return
+    }
+
+    task, err := scanTask(db.QueryRow(
+        `SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND user_id = $2`,
+        taskID, userID,
+    ))
+    if err != nil {
+        http.Error(w, `{"error": "Зависимость добавлена, но задача не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(task)
+}
+
+func DeleteTaskDependency(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+    dependsOnID, _ := strconv.Atoi(vars["dependsOnId"])
+
+    result, err := db.Exec(
+        `DELETE FROM task_dependencies
+         WHERE task_id = $1 AND depends_on_id = $2
+           AND task_id IN (SELECT id FROM tasks WHERE user_id = $3)`,
+        taskID, dependsOnID, userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления зависимости"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Зависимость не найдена"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Зависимость удалена"})
+}
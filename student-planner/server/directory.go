@@ -0,0 +1,442 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+const directorySearchLimit = 20
+
+var errDirectoryNotFound = errors.New("запись справочника не найдена")
+
+type Teacher struct {
+    ID          int       `json:"id"`
+    UserID      int       `json:"user_id"`
+    Name        string    `json:"name"`
+    Email       string    `json:"email"`
+    OfficeHours string    `json:"office_hours"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+type Room struct {
+    ID        int       `json:"id"`
+    UserID    int       `json:"user_id"`
+    Building  string    `json:"building"`
+    Number    string    `json:"number"`
+    Capacity  int       `json:"capacity"`
+    Label     string    `json:"label"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// RoomConflict описывает событие, уже занимающее аудиторию в это время.
+type RoomConflict struct {
+    ID       int       `json:"id"`
+    Title    string    `json:"title"`
+    StartsAt time.Time `json:"starts_at"`
+    EndsAt   time.Time `json:"ends_at"`
+}
+
+const teacherColumns = `id, user_id, name, COALESCE(email, ''), COALESCE(office_hours, ''), created_at`
+
+const roomColumns = `id, user_id, building, number, COALESCE(capacity, 0), created_at`
+
+// roomLabelSQL формирует подпись аудитории вида "Корпус 2, 305".
+const roomLabelSQL = `CONCAT_WS(', ', NULLIF(building, ''), NULLIF(number, ''))`
+
+const teacherNameColumn = `COALESCE((SELECT name FROM teachers WHERE teachers.id = teacher_id), '')`
+
+const roomLabelColumn = `COALESCE((SELECT ` + roomLabelSQL + ` FROM rooms WHERE rooms.id = room_id), '')`
+
+func scanTeacher(row rowScanner) (Teacher, error) {
+    var teacher Teacher
+    err := row.Scan(
+        &teacher.ID, &teacher.UserID, &teacher.Name, &teacher.Email,
+        &teacher.OfficeHours, &teacher.CreatedAt,
+    )
+    return teacher, err
+}
+
+func scanRoom(row rowScanner) (Room, error) {
+    var room Room
+    err := row.Scan(
+        &room.ID, &room.UserID, &room.Building, &room.Number,
+        &room.Capacity, &room.CreatedAt,
+    )
+    room.Label = roomLabel(room.Building, room.Number)
+    return room, err
+}
+
+func roomLabel(building, number string) string {
+    parts := []string{}
+    for _, part := range []string{building, number} {
+        if part != "" {
+            parts = append(parts, part)
+        }
+    }
+    return strings.Join(parts, ", ")
+}
+
+// resolveDirectoryID проверяет, что запись справочника table принадлежит пользователю.
+func resolveDirectoryID(q sqlQueryer, table string, id *int, userID int) (*int, error) {
+    if id == nil || *id <= 0 {
+        return nil, nil
+    }
+
+    var found int
+    err := q.QueryRow(
+        "SELECT id FROM "+table+" WHERE id = $1 AND user_id = $2",
+        *id, userID,
+    ).Scan(&found)
+    if err == sql.ErrNoRows {
+        return nil, errDirectoryNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &found, nil
+}
+
+// findRoomConflicts возвращает события пользователя в той же аудитории,
+// пересекающиеся с интервалом [startsAt, startsAt + duration).
+func findRoomConflicts(q sqlQueryer, userID, roomID, excludeEventID int, startsAt time.Time, durationHours float64) ([]RoomConflict, error) {
+    endsAt := startsAt.Add(time.Duration(durationHours * float64(time.Hour)))
+
+    rows, err := q.Query(
+        `SELECT id, title, starts_at, starts_at + duration_hours * INTERVAL '1 hour'
+         FROM events
+         WHERE user_id = $1 AND room_id = $2 AND id <> $3 AND deleted_at IS NULL
+           AND starts_at < $5
+           AND starts_at + duration_hours * INTERVAL '1 hour' > $4
+         ORDER BY starts_at`,
+        userID, roomID, excludeEventID, startsAt, endsAt,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    conflicts := []RoomConflict{}
+    for rows.Next() {
+        var conflict RoomConflict
+        if err := rows.Scan(&conflict.ID, &conflict.Title, &conflict.StartsAt, &conflict.EndsAt); err != nil {
+            continue
+        }
+        conflicts = append(conflicts, conflict)
+    }
+    return conflicts, nil
+}
+
+func writeRoomConflicts(w http.ResponseWriter, conflicts []RoomConflict) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusConflict)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "error":     "Аудитория уже занята в это время",
+        "conflicts": conflicts,
+    })
+}
+
+func GetTeachers(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    query := `SELECT ` + teacherColumns + ` FROM teachers WHERE user_id = $1`
+    args := []interface{}{userID}
+    if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+        query += ` AND (name ILIKE $2 OR email ILIKE $2) ORDER BY name LIMIT ` + strconv.Itoa(directorySearchLimit)
+        args = append(args, "%"+q+"%")
+    } else {
+        query += ` ORDER BY name`
+    }
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения преподавателей"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    teachers := []Teacher{}
+    for rows.Next() {
+        teacher, err := scanTeacher(rows)
+        if err != nil {
+            continue
+        }
+        teachers = append(teachers, teacher)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(teachers)
+}
+
+func CreateTeacher(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Name        string `json:"name"`
+        Email       string `json:"email"`
+        OfficeHours string `json:"office_hours"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        http.Error(w, `{"error": "Введите имя преподавателя"}`, http.StatusBadRequest)
+        return
+    }
+
+    teacher, err := scanTeacher(db.QueryRow(
+        `INSERT INTO teachers (user_id, name, email, office_hours)
+         VALUES ($1, $2, $3, $4)
+         RETURNING `+teacherColumns,
+        userID, req.Name, req.Email, req.OfficeHours,
+    ))
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания преподавателя"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(teacher)
+}
+
+func UpdateTeacher(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    teacherID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Name        string `json:"name"`
+        Email       string `json:"email"`
+        OfficeHours string `json:"office_hours"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        http.Error(w, `{"error": "Введите имя преподавателя"}`, http.StatusBadRequest)
+        return
+    }
+
+    teacher, err := scanTeacher(db.QueryRow(
+        `UPDATE teachers SET name = $1, email = $2, office_hours = $3
+         WHERE id = $4 AND user_id = $5
+         RETURNING `+teacherColumns,
+        req.Name, req.Email, req.OfficeHours, teacherID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Преподаватель не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления преподавателя"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(teacher)
+}
+
+func DeleteTeacher(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    teacherID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM teachers WHERE id = $1 AND user_id = $2", teacherID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления преподавателя"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Преподаватель не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Преподаватель удален"})
+}
+
+func GetRooms(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    query := `SELECT ` + roomColumns + ` FROM rooms WHERE user_id = $1`
+    args := []interface{}{userID}
+    if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+        query += ` AND ` + roomLabelSQL + ` ILIKE $2 ORDER BY building, number LIMIT ` + strconv.Itoa(directorySearchLimit)
+        args = append(args, "%"+q+"%")
+    } else {
+        query += ` ORDER BY building, number`
+    }
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения аудиторий"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    rooms := []Room{}
+    for rows.Next() {
+        room, err := scanRoom(rows)
+        if err != nil {
+            continue
+        }
+        rooms = append(rooms, room)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(rooms)
+}
+
+func CreateRoom(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Building string `json:"building"`
+        Number   string `json:"number"`
+        Capacity int    `json:"capacity"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Building = strings.TrimSpace(req.Building)
+    req.Number = strings.TrimSpace(req.Number)
+    if req.Number == "" {
+        http.Error(w, `{"error": "Введите номер аудитории"}`, http.StatusBadRequest)
+        return
+    }
+
+    room, err := scanRoom(db.QueryRow(
+        `INSERT INTO rooms (user_id, building, number, capacity)
+         VALUES ($1, $2, $3, NULLIF($4, 0))
+         ON CONFLICT (user_id, building, number) DO NOTHING
+         RETURNING `+roomColumns,
+        userID, req.Building, req.Number, req.Capacity,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Такая аудитория уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка создания аудитории"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(room)
+}
+
+func UpdateRoom(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    roomID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Building string `json:"building"`
+        Number   string `json:"number"`
+        Capacity int    `json:"capacity"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Building = strings.TrimSpace(req.Building)
+    req.Number = strings.TrimSpace(req.Number)
+    if req.Number == "" {
+        http.Error(w, `{"error": "Введите номер аудитории"}`, http.StatusBadRequest)
+        return
+    }
+
+    room, err := scanRoom(db.QueryRow(
+        `UPDATE rooms SET building = $1, number = $2, capacity = NULLIF($3, 0)
+         WHERE id = $4 AND user_id = $5
+         RETURNING `+roomColumns,
+        req.Building, req.Number, req.Capacity, roomID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Аудитория не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if isUniqueViolation(err) {
+        http.Error(w, `{"error": "Такая аудитория уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления аудитории"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(room)
+}
+
+func DeleteRoom(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    roomID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM rooms WHERE id = $1 AND user_id = $2", roomID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления аудитории"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Аудитория не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Аудитория удалена"})
+}
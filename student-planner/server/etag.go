@@ -0,0 +1,187 @@
+package main
+
+import (
+    "crypto/sha1"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gorilla/mux"
+)
+
+// versionETag — ETag отдельного события или задачи, построенный по номеру версии строки.
+func versionETag(version int) string {
+    return fmt.Sprintf(`"v%d"`, version)
+}
+
+// etagMatches проверяет заголовок If-Match/If-None-Match, содержащий список ETag или "*".
+func etagMatches(header, etag string) bool {
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+            return true
+        }
+    }
+    return false
+}
+
+// checkIfMatch проверяет If-Match перед изменением события или задачи. Без заголовка
+// изменение разрешено; при несовпадении версии отвечает 412 с текущей версией.
+// Возвращает версию, с которой согласен клиент (0 — без условия), и false, если ответ
+// уже записан. Между проверкой и записью строку могут изменить, поэтому сама запись
+// повторяет условие через versionCondition или lockVersion.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, entityType string, id, userID int) (int, bool) {
+    header := r.Header.Get("If-Match")
+    if header == "" || strings.TrimSpace(header) == "*" {
+        return 0, true
+    }
+
+    scope := editableTasksScope("$2")
+    if entityType == "event" {
+        scope = editableEventsScope("$2")
+    }
+
+    var version int
+    err := db.QueryRow(
+        "SELECT version FROM "+historyTables[entityType]+" WHERE id = $1 AND "+scope+" AND deleted_at IS NULL",
+        id, userID,
+    ).Scan(&version)
+    if err != nil {
+        // Отсутствие объекта сообщит сам обработчик.
+        return 0, true
+    }
+
+    if etagMatches(header, versionETag(version)) {
+        return version, true
+    }
+
+    writeVersionConflict(w, version)
+    return 0, false
+}
+
+// versionCondition — условие WHERE на версию строки из параметра param; 0 снимает условие.
+func versionCondition(param string) string {
+    return "(" + param + "::int = 0 OR version = " + param + ")"
+}
+
+// lockVersion блокирует строку до конца транзакции и сверяет её версию с ожидаемой,
+// когда изменение затрагивает несколько строк и условие нельзя вписать в один UPDATE.
+func lockVersion(tx *sql.Tx, entityType string, id, version int) (bool, error) {
+    if version == 0 {
+        return true, nil
+    }
+
+    var matches bool
+    err := tx.QueryRow(
+        "SELECT version = $2 FROM "+historyTables[entityType]+" WHERE id = $1 FOR UPDATE",
+        id, version,
+    ).Scan(&matches)
+    if err == sql.ErrNoRows {
+        return true, nil
+    }
+    return matches, err
+}
+
+// currentVersion возвращает версию строки для ответа 412; 0, если строки нет.
+func currentVersion(entityType string, id int) int {
+    var version int
+    db.QueryRow("SELECT version FROM "+historyTables[entityType]+" WHERE id = $1", id).Scan(&version)
+    return version
+}
+
+// writeVersionConflict отвечает 412 с текущей версией объекта.
+func writeVersionConflict(w http.ResponseWriter, version int) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("ETag", versionETag(version))
+    w.WriteHeader(http.StatusPreconditionFailed)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "error":   "Объект был изменён в другом месте, обновите данные",
+        "version": version,
+    })
+}
+
+// writeJSONWithETag отдаёт ответ с ETag; при совпадении If-None-Match — 304 без тела.
+// Пустой etag означает слабый ETag по содержимому ответа, для списков.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, etag string, v interface{}) {
+    body, err := json.Marshal(v)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка формирования ответа"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if etag == "" {
+        sum := sha1.Sum(body)
+        etag = `W/"` + hex.EncodeToString(sum[:]) + `"`
+    }
+
+    w.Header().Set("ETag", etag)
+    if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag) {
+        w.WriteHeader(http.StatusNotModified)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(append(body, '\n'))
+}
+
+func GetEvent(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    eventID, _ := strconv.Atoi(vars["id"])
+
+    event, err := scanEvent(db.QueryRow(
+        `SELECT `+eventColumns("$2")+` FROM events
+         WHERE id = $1 AND (`+visibleEventsScope("$2")+` OR `+sharedScope("event", "$2", false)+`) AND deleted_at IS NULL`,
+        eventID, userID,
+    ), userLocation(userID))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Событие не найдено или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения события"}`, http.StatusInternalServerError)
+        return
+    }
+
+    writeJSONWithETag(w, r, versionETag(event.Version), event)
+}
+
+// GetTask отдаёт задачу с подзадачами. ETag отражает версию самой задачи для If-Match,
+// поэтому 304 здесь не отдаётся: подзадачи могли измениться без смены версии.
+func GetTask(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+
+    ownerID, err := taskOwner(db, taskID, userID, "read")
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения задачи"}`, http.StatusInternalServerError)
+        return
+    }
+
+    task, err := loadTaskTree(taskID, ownerID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения задачи"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("ETag", versionETag(task.Version))
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(task)
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+type Grade struct {
+    ID        int       `json:"id"`
+    UserID    int       `json:"user_id"`
+    SubjectID int       `json:"subject_id"`
+    Subject   string    `json:"subject"`
+    EventID   *int      `json:"event_id"`
+    TaskID    *int      `json:"task_id"`
+    Name      string    `json:"name"`
+    Weight    float64   `json:"weight"`
+    Score     float64   `json:"score"`
+    MaxScore  float64   `json:"max_score"`
+    Percent   float64   `json:"percent"`
+    GradedOn  string    `json:"graded_on"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type gradeInput struct {
+    SubjectID *int     `json:"subject_id"`
+    EventID   *int     `json:"event_id"`
+    TaskID    *int     `json:"task_id"`
+    Name      string   `json:"name"`
+    Weight    *float64 `json:"weight"`
+    Score     float64  `json:"score"`
+    MaxScore  float64  `json:"max_score"`
+    GradedOn  string   `json:"graded_on"`
+}
+
+const gradeColumns = `id, user_id, subject_id, ` + subjectNameColumn + `, event_id, task_id, name,
+                weight, score, max_score, to_char(graded_on, 'YYYY-MM-DD'), created_at`
+
+func scanGrade(row rowScanner) (Grade, error) {
+    var grade Grade
+    var eventID, taskID sql.NullInt64
+    err := row.Scan(
+        &grade.ID, &grade.UserID, &grade.SubjectID, &grade.Subject, &eventID, &taskID,
+        &grade.Name, &grade.Weight, &grade.Score, &grade.MaxScore, &grade.GradedOn,
+        &grade.CreatedAt,
+    )
+    grade.EventID = nullIntPtr(eventID)
+    grade.TaskID = nullIntPtr(taskID)
+    if grade.MaxScore > 0 {
+        grade.Percent = roundTo(grade.Score/grade.MaxScore*100, 2)
+    }
+    return grade, err
+}
+
+func roundTo(value float64, digits int) float64 {
+    pow := math.Pow(10, float64(digits))
+    return math.Round(value*pow) / pow
+}
+
+// grade5 переводит процент в оценку по пятибалльной шкале.
+func grade5(percent float64) int {
+    switch {
+    case percent >= 85:
+        return 5
+    case percent >= 70:
+        return 4
+    case percent >= 50:
+        return 3
+    default:
+        return 2
+    }
+}
+
+// gpa4 переводит процент в балл по американской шкале 4.0.
+func gpa4(percent float64) float64 {
+    scale := []struct {
+        min    float64
+        points float64
+    }{
+        {93, 4.0}, {90, 3.7}, {87, 3.3}, {83, 3.0}, {80, 2.7}, {77, 2.3},
+        {73, 2.0}, {70, 1.7}, {67, 1.3}, {60, 1.0},
+    }
+    for _, step := range scale {
+        if percent >= step.min {
+            return step.points
+        }
+    }
+    return 0
+}
+
+// resolveGradeLinks проверяет связанные событие, задачу и предмет оценки.
+// Если предмет не указан, он берётся из события или задачи.
+func resolveGradeLinks(userID int, req gradeInput) (int, string) {
+    var subjectID sql.NullInt64
+
+    if req.EventID != nil {
+        err := db.QueryRow(
+            "SELECT subject_id FROM events WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+            *req.EventID, userID,
+        ).Scan(&subjectID)
+        if err != nil {
+            return 0, "Событие не найдено"
+        }
+    }
+
+    if req.TaskID != nil {
+        var taskSubjectID sql.NullInt64
+        err := db.QueryRow(
+            "SELECT subject_id FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+            *req.TaskID, userID,
+        ).Scan(&taskSubjectID)
+        if err != nil {
+            return 0, "Задача не найдена"
+        }
+        if !subjectID.Valid {
+            subjectID = taskSubjectID
+        }
+    }
+
+    if req.SubjectID != nil {
+        id, err := resolveSubject(db, userID, req.SubjectID, "")
+        if err != nil || id == nil {
+            return 0, "Предмет не найден"
+        }
+        return *id, ""
+    }
+
+    if !subjectID.Valid {
+        return 0, "Укажите предмет оценки"
+    }
+    return int(subjectID.Int64), ""
+}
+
+// validate проверяет оценку и заполняет значения по умолчанию: вес 1, если он не передан
+// (нулевой вес — работа не влияет на средний балл), и сегодняшнюю дату в поясе loc.
+func (req *gradeInput) validate(loc *time.Location) string {
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        return "Введите название работы"
+    }
+    if req.MaxScore <= 0 {
+        return "Максимальный балл должен быть больше нуля"
+    }
+    if req.Score < 0 || req.Score > req.MaxScore {
+        return "Балл должен быть от 0 до максимального"
+    }
+    if req.Weight == nil {
+        weight := 1.0
+        req.Weight = &weight
+    }
+    if *req.Weight < 0 {
+        return "Вес работы не может быть отрицательным"
+    }
+    if req.GradedOn == "" {
+        req.GradedOn = time.Now().In(loc).Format("2006-01-02")
+    } else if _, err := time.Parse("2006-01-02", req.GradedOn); err != nil {
+        return "Неверная дата оценки"
+    }
+    return ""
+}
+
+func GetGrades(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    query := `SELECT ` + gradeColumns + ` FROM grades WHERE user_id = $1`
+    args := []interface{}{userID}
+    if subjectID, ok := subjectFilter(r); ok {
+        args = append(args, subjectID)
+        query += fmt.Sprintf(" AND subject_id = $%d", len(args))
+    }
+    query += " ORDER BY graded_on DESC, id DESC"
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения оценок"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    grades := []Grade{}
+    for rows.Next() {
+        grade, err := scanGrade(rows)
+        if err != nil {
+            continue
+        }
+        grades = append(grades, grade)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(grades)
+}
+
+func CreateGrade(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req gradeInput
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if msg := req.validate(userLocation(userID)); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    subjectID, msg := resolveGradeLinks(userID, req)
+    if msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    grade, err := scanGrade(db.QueryRow(
+        `INSERT INTO grades (user_id, subject_id, event_id, task_id, name, weight, score, max_score, graded_on)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+         RETURNING `+gradeColumns,
+        userID, subjectID, req.EventID, req.TaskID, req.Name, *req.Weight,
+        req.Score, req.MaxScore, req.GradedOn,
+    ))
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания оценки"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(grade)
+}
+
+func UpdateGrade(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    gradeID, _ := strconv.Atoi(vars["id"])
+
+    var req gradeInput
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if msg := req.validate(userLocation(userID)); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    subjectID, msg := resolveGradeLinks(userID, req)
+    if msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    grade, err := scanGrade(db.QueryRow(
+        `UPDATE grades
+         SET subject_id = $1, event_id = $2, task_id = $3, name = $4, weight = $5,
+             score = $6, max_score = $7, graded_on = $8
+         WHERE id = $9 AND user_id = $10
+         RETURNING `+gradeColumns,
+        subjectID, req.EventID, req.TaskID, req.Name, *req.Weight,
+        req.Score, req.MaxScore, req.GradedOn, gradeID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Оценка не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления оценки"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(grade)
+}
+
+func DeleteGrade(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    gradeID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM grades WHERE id = $1 AND user_id = $2", gradeID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления оценки"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Оценка не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Оценка удалена"})
+}
+
+func GetGradeStats(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    type SubjectGrades struct {
+        SubjectID       int     `json:"subject_id"`
+        Name            string  `json:"name"`
+        Credits         float64 `json:"credits"`
+        Assessments     int     `json:"assessments"`
+        WeightedPercent float64 `json:"weighted_percent"`
+        Grade5          int     `json:"grade_5"`
+        GPA4            float64 `json:"gpa_4"`
+    }
+
+    type GradeStats struct {
+        TermID         *int            `json:"term_id"`
+        Subjects       []SubjectGrades `json:"subjects"`
+        AveragePercent float64         `json:"average_percent"`
+        GPA5           float64         `json:"gpa_5"`
+        GPA4           float64         `json:"gpa_4"`
+    }
+
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+
+    query := `SELECT g.subject_id, s.name, s.credits,
+                     COUNT(*), SUM(g.weight * g.score / g.max_score), SUM(g.weight)
+              FROM grades g JOIN subjects s ON s.id = g.subject_id
+              WHERE g.user_id = $1`
+    args := []interface{}{userID}
+    if term != nil {
+        args = append(args, term.StartDate, term.EndDate)
+        query += fmt.Sprintf(" AND g.graded_on BETWEEN $%d AND $%d", len(args)-1, len(args))
+    }
+    query += " GROUP BY g.subject_id, s.name, s.credits ORDER BY s.name"
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    stats := GradeStats{Subjects: []SubjectGrades{}}
+    if term != nil {
+        stats.TermID = &term.ID
+    }
+
+    // Общий балл взвешивается по кредитам; предметы без кредитов считаются за один.
+    var totalCredits, percentSum, grade5Sum, gpa4Sum float64
+    for rows.Next() {
+        var item SubjectGrades
+        var weightedScore, totalWeight float64
+        err := rows.Scan(
+            &item.SubjectID, &item.Name, &item.Credits,
+            &item.Assessments, &weightedScore, &totalWeight,
+        )
+        if err != nil || totalWeight <= 0 {
+            continue
+        }
+
+        percent := weightedScore / totalWeight * 100
+        item.WeightedPercent = roundTo(percent, 2)
+        item.Grade5 = grade5(percent)
+        item.GPA4 = gpa4(percent)
+        stats.Subjects = append(stats.Subjects, item)
+
+        credits := item.Credits
+        if credits <= 0 {
+            credits = 1
+        }
+        totalCredits += credits
+        percentSum += percent * credits
+        grade5Sum += float64(item.Grade5) * credits
+        gpa4Sum += item.GPA4 * credits
+    }
+
+    if totalCredits > 0 {
+        stats.AveragePercent = roundTo(percentSum/totalCredits, 2)
+        stats.GPA5 = roundTo(grade5Sum/totalCredits, 2)
+        stats.GPA4 = roundTo(gpa4Sum/totalCredits, 2)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestGrade5(t *testing.T) {
+    cases := []struct {
+        percent float64
+        want    int
+    }{
+        {100, 5},
+        {85, 5},
+        {84.99, 4},
+        {70, 4},
+        {69.99, 3},
+        {50, 3},
+        {49.99, 2},
+        {0, 2},
+    }
+    for _, c := range cases {
+        if got := grade5(c.percent); got != c.want {
+            t.Errorf("grade5(%v) = %d, want %d", c.percent, got, c.want)
+        }
+    }
+}
+
+func TestGPA4(t *testing.T) {
+    cases := []struct {
+        percent float64
+        want    float64
+    }{
+        {100, 4.0},
+        {93, 4.0},
+        {92.99, 3.7},
+        {90, 3.7},
+        {89.99, 3.3},
+        {87, 3.3},
+        {83, 3.0},
+        {80, 2.7},
+        {77, 2.3},
+        {73, 2.0},
+        {70, 1.7},
+        {67, 1.3},
+        {66.99, 1.0},
+        {60, 1.0},
+        {59.99, 0},
+        {0, 0},
+    }
+    for _, c := range cases {
+        if got := gpa4(c.percent); got != c.want {
+            t.Errorf("gpa4(%v) = %v, want %v", c.percent, got, c.want)
+        }
+    }
+}
+
+func TestGradeInputValidateWeight(t *testing.T) {
+    zero, half, negative := 0.0, 0.5, -1.0
+    cases := []struct {
+        name    string
+        weight  *float64
+        want    string
+        wantVal float64
+    }{
+        {"missing weight defaults to 1", nil, "", 1},
+        {"zero weight is kept", &zero, "", 0},
+        {"fractional weight", &half, "", 0.5},
+        {"negative weight", &negative, "Вес работы не может быть отрицательным", -1},
+    }
+    for _, c := range cases {
+        req := gradeInput{Name: "Контрольная", Score: 8, MaxScore: 10, GradedOn: "2024-10-01", Weight: c.weight}
+        if got := req.validate(time.UTC); got != c.want {
+            t.Errorf("%s: validate = %q, want %q", c.name, got, c.want)
+            continue
+        }
+        if req.Weight == nil || *req.Weight != c.wantVal {
+            t.Errorf("%s: weight = %v, want %v", c.name, req.Weight, c.wantVal)
+        }
+    }
+}
+
+func TestGradeInputValidateDefaultDate(t *testing.T) {
+    // Пояс на 14 часов впереди UTC: почти всегда уже другая дата, чем по UTC.
+    loc := time.FixedZone("UTC+14", 14*60*60)
+    req := gradeInput{Name: "Контрольная", Score: 8, MaxScore: 10}
+    if msg := req.validate(loc); msg != "" {
+        t.Fatalf("validate = %q, want no error", msg)
+    }
+    if want := time.Now().In(loc).Format("2006-01-02"); req.GradedOn != want {
+        t.Errorf("graded_on = %s, want %s", req.GradedOn, want)
+    }
+}
@@ -0,0 +1,798 @@
+package main
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "encoding/base32"
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+    "github.com/lib/pq"
+)
+
+// groupRoleRank упорядочивает роли участников группы по объёму прав.
+var groupRoleRank = map[string]int{
+    "viewer": 1,
+    "editor": 2,
+    "owner":  3,
+}
+
+type StudyGroup struct {
+    ID         int           `json:"id"`
+    Name       string        `json:"name"`
+    InviteCode string        `json:"invite_code"`
+    OwnerID    int           `json:"owner_id"`
+    Role       string        `json:"role"`
+    Members    int           `json:"members"`
+    MemberList []GroupMember `json:"member_list,omitempty"`
+    CreatedAt  time.Time     `json:"created_at"`
+}
+
+type GroupMember struct {
+    UserID   int       `json:"user_id"`
+    Name     string    `json:"name"`
+    Email    string    `json:"email"`
+    Role     string    `json:"role"`
+    JoinedAt time.Time `json:"joined_at"`
+}
+
+type GroupInvite struct {
+    ID        int       `json:"id"`
+    GroupID   int       `json:"group_id"`
+    Group     string    `json:"group"`
+    Email     string    `json:"email"`
+    Role      string    `json:"role"`
+    InvitedBy string    `json:"invited_by"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupEvent — общее событие группы с отметкой, скрыл ли его текущий участник.
+type GroupEvent struct {
+    Event
+    Hidden bool `json:"hidden"`
+}
+
+// groupColumns выбираются из study_groups g, соединённой с group_members m текущего пользователя.
+const groupColumns = `g.id, g.name, g.invite_code, g.owner_id, m.role,
+                (SELECT COUNT(*) FROM group_members c WHERE c.group_id = g.id), g.created_at`
+
+func scanGroup(row rowScanner) (StudyGroup, error) {
+    var group StudyGroup
+    err := row.Scan(
+        &group.ID, &group.Name, &group.InviteCode, &group.OwnerID, &group.Role,
+        &group.Members, &group.CreatedAt,
+    )
+    return group, err
+}
+
+// visibleEventsScope — условие на события, которые видит пользователь из параметра param:
+// свои и общие события его групп, кроме скрытых им самим.
+func visibleEventsScope(param string) string {
+    return `(user_id = ` + param + ` OR group_id IN (SELECT group_id FROM group_members WHERE user_id = ` + param + `))
+         AND id NOT IN (SELECT event_id FROM group_event_overrides WHERE user_id = ` + param + ` AND hidden)`
+}
+
+// editableEventsScope — условие на события, которые пользователь из параметра param может менять:
+// свои, общие события групп, где он владелец или редактор, и полученные с правом edit.
+func editableEventsScope(param string) string {
+    return `(user_id = ` + param + ` OR group_id IN (SELECT group_id FROM group_members
+             WHERE user_id = ` + param + ` AND role IN ('owner', 'editor'))
+             OR ` + sharedScope("event", param, true) + `)`
+}
+
+// eventOwner возвращает автора события, которое пользователь может менять.
+func eventOwner(q sqlQueryer, eventID, userID int) (int, error) {
+    var ownerID int
+    err := q.QueryRow(
+        "SELECT user_id FROM events WHERE id = $1 AND deleted_at IS NULL AND "+editableEventsScope("$2"),
+        eventID, userID,
+    ).Scan(&ownerID)
+    return ownerID, err
+}
+
+// groupRole возвращает роль пользователя в группе; пустая строка — не участник.
+func groupRole(groupID, userID int) (string, error) {
+    var role string
+    err := db.QueryRow(
+        "SELECT role FROM group_members WHERE group_id = $1 AND user_id = $2",
+        groupID, userID,
+    ).Scan(&role)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    return role, err
+}
+
+// requireGroupRole проверяет, что роль пользователя в группе не ниже min.
+// Не участнику группа не видна (404), участнику без прав отвечает 403.
+func requireGroupRole(w http.ResponseWriter, groupID, userID int, min string) bool {
+    role, err := groupRole(groupID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки прав в группе"}`, http.StatusInternalServerError)
+        return false
+    }
+    if role == "" {
+        http.Error(w, `{"error": "Группа не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return false
+    }
+    if groupRoleRank[role] < groupRoleRank[min] {
+        http.Error(w, `{"error": "Недостаточно прав в группе"}`, http.StatusForbidden)
+        return false
+    }
+    return true
+}
+
+// newInviteCode генерирует код приглашения из восьми символов base32.
+func newInviteCode() (string, error) {
+    buf := make([]byte, 5)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return base32.StdEncoding.EncodeToString(buf), nil
+}
+
+func loadGroup(groupID, userID int) (StudyGroup, error) {
+    return scanGroup(db.QueryRow(
+        `SELECT `+groupColumns+`
+         FROM study_groups g JOIN group_members m ON m.group_id = g.id AND m.user_id = $2
+         WHERE g.id = $1`,
+        groupID, userID,
+    ))
+}
+
+func GetGroups(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    rows, err := db.Query(
+        `SELECT `+groupColumns+`
+         FROM study_groups g JOIN group_members m ON m.group_id = g.id AND m.user_id = $1
+         ORDER BY g.name`,
+        userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения групп"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    groups := []StudyGroup{}
+    for rows.Next() {
+        group, err := scanGroup(rows)
+        if err != nil {
+            continue
+        }
+        groups = append(groups, group)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(groups)
+}
+
+// CreateGroup доступен любому незаблокированному пользователю; создатель становится владельцем группы.
+func CreateGroup(w http.ResponseWriter, r *http.Request) {
+    userID := authorize(w, r, actionCreateGroup)
+    if userID == 0 {
+        return
+    }
+
+    var req struct {
+        Name string `json:"name"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        writeBadRequest(w, "Введите название группы")
+        return
+    }
+
+    code, err := newInviteCode()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания группы"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    var groupID int
+    err = tx.QueryRow(
+        "INSERT INTO study_groups (name, invite_code, owner_id) VALUES ($1, $2, $3) RETURNING id",
+        req.Name, code, userID,
+    ).Scan(&groupID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if _, err := tx.Exec(
+        "INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'owner')",
+        groupID, userID,
+    ); err != nil {
+        http.Error(w, `{"error": "Ошибка создания группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка создания группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    group, err := loadGroup(groupID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Группа создана, но не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(group)
+}
+
+func GetGroup(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+
+    group, err := loadGroup(groupID, userID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Группа не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rows, err := db.Query(
+        `SELECT u.id, u.name, u.email, m.role, m.joined_at
+         FROM group_members m JOIN users u ON u.id = m.user_id
+         WHERE m.group_id = $1
+         ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, u.name`,
+        groupID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения участников группы"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    group.MemberList = []GroupMember{}
+    for rows.Next() {
+        var member GroupMember
+        if err := rows.Scan(&member.UserID, &member.Name, &member.Email, &member.Role, &member.JoinedAt); err != nil {
+            continue
+        }
+        group.MemberList = append(group.MemberList, member)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(group)
+}
+
+func UpdateGroup(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Name string `json:"name"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        writeBadRequest(w, "Введите название группы")
+        return
+    }
+
+    if !requireGroupRole(w, groupID, userID, "owner") {
+        return
+    }
+
+    if _, err := db.Exec("UPDATE study_groups SET name = $1 WHERE id = $2", req.Name, groupID); err != nil {
+        http.Error(w, `{"error": "Ошибка обновления группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    group, err := loadGroup(groupID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Группа обновлена, но не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(group)
+}
+
+// DeleteGroup удаляет группу; её общие события остаются личными событиями их авторов.
+func DeleteGroup(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+
+    if !requireGroupRole(w, groupID, userID, "owner") {
+        return
+    }
+
+    if _, err := db.Exec("DELETE FROM study_groups WHERE id = $1", groupID); err != nil {
+        http.Error(w, `{"error": "Ошибка удаления группы"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Группа удалена"})
+}
+
+// RegenerateInviteCode выдаёт группе новый код, старый перестаёт действовать.
+func RegenerateInviteCode(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+
+    if !requireGroupRole(w, groupID, userID, "owner") {
+        return
+    }
+
+    code, err := newInviteCode()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления кода приглашения"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if _, err := db.Exec("UPDATE study_groups SET invite_code = $1 WHERE id = $2", code, groupID); err != nil {
+        http.Error(w, `{"error": "Ошибка обновления кода приглашения"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"invite_code": code})
+}
+
+// JoinGroup добавляет пользователя в группу по коду приглашения с ролью viewer.
+func JoinGroup(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Code string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    var groupID int
+    err := db.QueryRow(
+        "SELECT id FROM study_groups WHERE invite_code = $1",
+        strings.ToUpper(strings.TrimSpace(req.Code)),
+    ).Scan(&groupID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Неверный код приглашения"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка вступления в группу"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if _, err := db.Exec(
+        "INSERT INTO group_members (group_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+        groupID, userID,
+    ); err != nil {
+        http.Error(w, `{"error": "Ошибка вступления в группу"}`, http.StatusInternalServerError)
+        return
+    }
+
+    group, err := loadGroup(groupID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Вы вступили в группу, но она не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(group)
+}
+
+// InviteToGroup приглашает пользователя по email; приглашение ждёт, пока он его примет.
+func InviteToGroup(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Email string `json:"email"`
+        Role  string `json:"role"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+    if req.Email == "" {
+        writeBadRequest(w, "Введите email приглашаемого")
+        return
+    }
+    if req.Role == "" {
+        req.Role = "viewer"
+    }
+    if req.Role != "editor" && req.Role != "viewer" {
+        writeBadRequest(w, "Роль должна быть editor или viewer")
+        return
+    }
+
+    if !requireGroupRole(w, groupID, userID, "owner") {
+        return
+    }
+
+    var isMember bool
+    db.QueryRow(
+        `SELECT EXISTS (SELECT 1 FROM group_members m JOIN users u ON u.id = m.user_id
+                        WHERE m.group_id = $1 AND LOWER(u.email) = $2)`,
+        groupID, req.Email,
+    ).Scan(&isMember)
+    if isMember {
+        http.Error(w, `{"error": "Пользователь уже состоит в группе"}`, http.StatusConflict)
+        return
+    }
+
+    var invite GroupInvite
+    err := db.QueryRow(
+        `INSERT INTO group_invites (group_id, email, role, invited_by) VALUES ($1, $2, $3, $4)
+         ON CONFLICT (group_id, email) DO UPDATE SET role = EXCLUDED.role, invited_by = EXCLUDED.invited_by
+         RETURNING id, group_id, email, role, created_at`,
+        groupID, req.Email, req.Role, userID,
+    ).Scan(&invite.ID, &invite.GroupID, &invite.Email, &invite.Role, &invite.CreatedAt)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания приглашения"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(invite)
+}
+
+// GetGroupInvites возвращает приглашения, отправленные на email текущего пользователя.
+func GetGroupInvites(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    rows, err := db.Query(
+        `SELECT i.id, i.group_id, g.name, i.email, i.role, COALESCE(inviter.name, ''), i.created_at
+         FROM group_invites i
+         JOIN study_groups g ON g.id = i.group_id
+         JOIN users u ON LOWER(u.email) = i.email
+         LEFT JOIN users inviter ON inviter.id = i.invited_by
+         WHERE u.id = $1
+         ORDER BY i.created_at DESC`,
+        userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения приглашений"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    invites := []GroupInvite{}
+    for rows.Next() {
+        var invite GroupInvite
+        err := rows.Scan(
+            &invite.ID, &invite.GroupID, &invite.Group, &invite.Email, &invite.Role,
+            &invite.InvitedBy, &invite.CreatedAt,
+        )
+        if err != nil {
+            continue
+        }
+        invites = append(invites, invite)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(invites)
+}
+
+// RespondGroupInvite принимает (POST) или отклоняет (DELETE) приглашение в группу.
+func RespondGroupInvite(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    inviteID, _ := strconv.Atoi(vars["inviteId"])
+
+    var groupID int
+    var role string
+    err := db.QueryRow(
+        `DELETE FROM group_invites i USING users u
+         WHERE i.id = $1 AND u.id = $2 AND LOWER(u.email) = i.email
+         RETURNING i.group_id, i.role`,
+        inviteID, userID,
+    ).Scan(&groupID, &role)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Приглашение не найдено"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обработки приглашения"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if r.Method == http.MethodDelete {
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]string{"message": "Приглашение отклонено"})
+        return
+    }
+
+    if _, err := db.Exec(
+        "INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+        groupID, userID, role,
+    ); err != nil {
+        http.Error(w, `{"error": "Ошибка вступления в группу"}`, http.StatusInternalServerError)
+        return
+    }
+
+    group, err := loadGroup(groupID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Вы вступили в группу, но она не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(group)
+}
+
+// UpdateGroupMember меняет роль участника; роль владельца не передаётся.
+func UpdateGroupMember(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+    memberID, _ := strconv.Atoi(vars["userId"])
+
+    var req struct {
+        Role string `json:"role"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if req.Role != "editor" && req.Role != "viewer" {
+        writeBadRequest(w, "Роль должна быть editor или viewer")
+        return
+    }
+
+    if !requireGroupRole(w, groupID, userID, "owner") {
+        return
+    }
+
+    result, err := db.Exec(
+        "UPDATE group_members SET role = $1 WHERE group_id = $2 AND user_id = $3 AND role <> 'owner'",
+        req.Role, groupID, memberID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка изменения роли"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Участник не найден или это владелец группы"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Роль участника изменена"})
+}
+
+// RemoveGroupMember исключает участника (владельцем) или выходит из группы (сам участник).
+// Владелец выйти не может — группу нужно удалить.
+func RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+    memberID, _ := strconv.Atoi(vars["userId"])
+
+    minRole := "owner"
+    if memberID == userID {
+        minRole = "viewer"
+    }
+    if !requireGroupRole(w, groupID, userID, minRole) {
+        return
+    }
+
+    result, err := db.Exec(
+        "DELETE FROM group_members WHERE group_id = $1 AND user_id = $2 AND role <> 'owner'",
+        groupID, memberID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления участника"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Участник не найден или это владелец группы"}`, http.StatusNotFound)
+        return
+    }
+
+    // Переопределения бывшего участника больше ни на что не влияют.
+    db.Exec(
+        `DELETE FROM group_event_overrides
+         WHERE user_id = $1 AND event_id IN (SELECT id FROM events WHERE group_id = $2)`,
+        memberID, groupID,
+    )
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Участник удалён из группы"})
+}
+
+// GetGroupEvents возвращает все общие события группы, включая скрытые текущим участником.
+func GetGroupEvents(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+
+    if !requireGroupRole(w, groupID, userID, "viewer") {
+        return
+    }
+
+    hidden := make(map[int]bool)
+    var hiddenIDs pq.Int64Array
+    db.QueryRow(
+        `SELECT COALESCE(array_agg(event_id), '{}') FROM group_event_overrides
+         WHERE user_id = $1 AND hidden`,
+        userID,
+    ).Scan(&hiddenIDs)
+    for _, id := range hiddenIDs {
+        hidden[int(id)] = true
+    }
+
+    rows, err := db.Query(
+        `SELECT `+eventColumns("$2")+` FROM events WHERE group_id = $1 AND deleted_at IS NULL ORDER BY starts_at`,
+        groupID, userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения событий группы"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    loc := userLocation(userID)
+    events := []GroupEvent{}
+    for rows.Next() {
+        event, err := scanEvent(rows, loc)
+        if err != nil {
+            continue
+        }
+        events = append(events, GroupEvent{Event: event, Hidden: hidden[event.ID]})
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(events)
+}
+
+// SetGroupEventOverride скрывает (hidden: true) или возвращает общее событие группы
+// только для текущего участника. DELETE снимает переопределение.
+func SetGroupEventOverride(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    groupID, _ := strconv.Atoi(vars["id"])
+    eventID, _ := strconv.Atoi(vars["eventId"])
+
+    var req struct {
+        Hidden bool `json:"hidden"`
+    }
+    if r.Method != http.MethodDelete {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+            return
+        }
+    }
+
+    if !requireGroupRole(w, groupID, userID, "viewer") {
+        return
+    }
+
+    var exists bool
+    db.QueryRow(
+        "SELECT EXISTS (SELECT 1 FROM events WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL)",
+        eventID, groupID,
+    ).Scan(&exists)
+    if !exists {
+        http.Error(w, `{"error": "Событие группы не найдено"}`, http.StatusNotFound)
+        return
+    }
+
+    var err error
+    if r.Method == http.MethodDelete {
+        _, err = db.Exec(
+            "DELETE FROM group_event_overrides WHERE event_id = $1 AND user_id = $2",
+            eventID, userID,
+        )
+    } else {
+        _, err = db.Exec(
+            `INSERT INTO group_event_overrides (event_id, user_id, hidden) VALUES ($1, $2, $3)
+             ON CONFLICT (event_id, user_id) DO UPDATE SET hidden = EXCLUDED.hidden`,
+            eventID, userID, req.Hidden,
+        )
+    }
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка сохранения настройки события"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "event_id": eventID,
+        "hidden":   req.Hidden,
+    })
+}
@@ -3,63 +3,241 @@ package main
 import (
     "database/sql"
     "encoding/json"
+    "fmt"
     "net/http"
     "strconv"
+    "strings"
     "time"
     
     "github.com/gorilla/mux"
+    "github.com/lib/pq"
     "golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-    ID        int       `json:"id"`
-    Email     string    `json:"email"`
-    Password  string    `json:"-"`
-    Name      string    `json:"name"`
-    CreatedAt time.Time `json:"created_at"`
+    ID               int       `json:"id"`
+    Email            string    `json:"email"`
+    Password         string    `json:"-"`
+    Name             string    `json:"name"`
+    Timezone         string    `json:"timezone"`
+    Role             string    `json:"role"`
+    TwoFactorEnabled bool      `json:"two_factor_enabled"`
+    CreatedAt        time.Time `json:"created_at"`
 }
 
 type Event struct {
     ID           int       `json:"id"`
     UserID       int       `json:"user_id"`
+    GroupID      *int      `json:"group_id"`
     Title        string    `json:"title"`
     Description  string    `json:"description"`
     EventType    string    `json:"event_type"`
+    SubjectID    *int      `json:"subject_id"`
     Subject      string    `json:"subject"`
     Location     string    `json:"location"`
+    TeacherID    *int      `json:"teacher_id"`
+    Teacher      string    `json:"teacher"`
+    RoomID       *int      `json:"room_id"`
+    Room         string    `json:"room"`
+    StartsAt     time.Time `json:"starts_at"`
     EventDate    string    `json:"event_date"`
     StartTime    string    `json:"start_time"`
     DurationHours float64   `json:"duration_hours"`
+    Attendance   string    `json:"attendance"`
+    Tags         []Tag     `json:"tags"`
+    Version      int       `json:"version"`
+    UpdatedAt    time.Time `json:"updated_at"`
     CreatedAt    time.Time `json:"created_at"`
 }
 
-type Task struct {
-    ID          int       `json:"id"`
-    UserID      int       `json:"user_id"`
-    Title       string    `json:"title"`
-    Description string    `json:"description"`
-    Priority    string    `json:"priority"`
-    IsCompleted bool      `json:"is_completed"`
-    DueDate     string    `json:"due_date"`
-    CreatedAt   time.Time `json:"created_at"`
+type eventInput struct {
+    Title        string  `json:"title"`
+    Description  string  `json:"description"`
+    EventType    string  `json:"event_type"`
+    SubjectID    *int    `json:"subject_id"`
+    Subject      string  `json:"subject"`
+    Location     string  `json:"location"`
+    TeacherID    *int    `json:"teacher_id"`
+    RoomID       *int    `json:"room_id"`
+    StartsAt     string  `json:"starts_at"`
+    EventDate    string  `json:"event_date"`
+    StartTime    string  `json:"start_time"`
+    DurationHours float64 `json:"duration_hours"`
+    TagIDs       []int   `json:"tag_ids"`
+    GroupID      *int    `json:"group_id"`
 }
 
-func getUserIdFromRequest(r *http.Request) int {
-    userIdStr := r.Header.Get("X-User-ID")
-    if userIdStr != "" {
-        if userId, err := strconv.Atoi(userIdStr); err == nil && userId > 0 {
-            return userId
-        }
+type eventLinks struct {
+    SubjectID *int
+    TeacherID *int
+    RoomID    *int
+}
+
+// resolveEventLinks проверяет ссылки события на предмет, преподавателя и аудиторию.
+func resolveEventLinks(q sqlQueryer, userID int, req eventInput) (eventLinks, error) {
+    var links eventLinks
+    var err error
+    
+    if links.SubjectID, err = resolveSubject(q, userID, req.SubjectID, req.Subject); err != nil {
+        return links, err
     }
+    if links.TeacherID, err = resolveDirectoryID(q, "teachers", req.TeacherID, userID); err != nil {
+        return links, err
+    }
+    if links.RoomID, err = resolveDirectoryID(q, "rooms", req.RoomID, userID); err != nil {
+        return links, err
+    }
+    return links, nil
+}
 
-    userIdStr = r.URL.Query().Get("user_id")
-    if userIdStr != "" {
-        if userId, err := strconv.Atoi(userIdStr); err == nil && userId > 0 {
-            return userId
-        }
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+    if !n.Valid {
+        return nil
     }
+    v := int(n.Int64)
+    return &v
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+    if !t.Valid {
+        return nil
+    }
+    return &t.Time
+}
 
-    return 0
+// eventColumns — колонки события для scanEvent; отметка посещаемости берётся
+// для пользователя из параметра viewer.
+func eventColumns(viewer string) string {
+    return `id, user_id, group_id, title, COALESCE(description, ''), event_type, subject_id, ` + subjectNameColumn + `,
+                COALESCE(location, ''), teacher_id, ` + teacherNameColumn + `, room_id, ` + roomLabelColumn + `,
+                starts_at, duration_hours, ` + attendanceColumn(viewer) + `, ` + eventTagsColumn + `, version, updated_at, created_at`
+}
+
+// scanEvent читает строку events и переводит начало события в часовой пояс пользователя.
+func scanEvent(row rowScanner, loc *time.Location) (Event, error) {
+    var event Event
+    var groupID, subjectID, teacherID, roomID sql.NullInt64
+    var tags []byte
+    err := row.Scan(
+        &event.ID, &event.UserID, &groupID, &event.Title, &event.Description,
+        &event.EventType, &subjectID, &event.Subject, &event.Location,
+        &teacherID, &event.Teacher, &roomID, &event.Room, &event.StartsAt,
+        &event.DurationHours, &event.Attendance, &tags, &event.Version,
+        &event.UpdatedAt, &event.CreatedAt,
+    )
+    if err != nil {
+        return event, err
+    }
+
+    event.GroupID = nullIntPtr(groupID)
+    event.SubjectID = nullIntPtr(subjectID)
+    event.TeacherID = nullIntPtr(teacherID)
+    event.RoomID = nullIntPtr(roomID)
+    event.Tags = tagsFromJSON(tags)
+    event.StartsAt = event.StartsAt.In(loc)
+    event.EventDate = event.StartsAt.Format("2006-01-02")
+    event.StartTime = event.StartsAt.Format("15:04")
+    return event, nil
+}
+
+type Task struct {
+    ID          int             `json:"id"`
+    UserID      int             `json:"user_id"`
+    Title       string          `json:"title"`
+    Description string          `json:"description"`
+    Priority    string          `json:"priority"`
+    IsCompleted bool            `json:"is_completed"`
+    Status      string          `json:"status"`
+    StartedAt   *time.Time      `json:"started_at"`
+    CompletedAt *time.Time      `json:"completed_at"`
+    DueDate     string          `json:"due_date"`
+    DueTime     string          `json:"due_time"`
+    Deadline    *time.Time      `json:"deadline"`
+    HoursLeft   *float64        `json:"hours_left"`
+    IsOverdue   bool            `json:"is_overdue"`
+    SubjectID   *int            `json:"subject_id"`
+    Subject     string          `json:"subject"`
+    ParentID    *int            `json:"parent_id"`
+    Position    int             `json:"position"`
+    Recurrence  *TaskRecurrence `json:"recurrence"`
+    NextTaskID  *int            `json:"next_task_id"`
+    Progress    float64         `json:"progress"`
+    Subtasks    []Task          `json:"subtasks,omitempty"`
+    DependsOn   []int           `json:"depends_on"`
+    BlockedBy   []int           `json:"blocked_by"`
+    Blocked     bool            `json:"blocked"`
+    Tags        []Tag           `json:"tags"`
+    Version     int             `json:"version"`
+    UpdatedAt   time.Time       `json:"updated_at"`
+    CreatedAt   time.Time       `json:"created_at"`
+}
+
+const taskColumns = `id, user_id, title, COALESCE(description, ''), priority, is_completed, status, started_at, completed_at,
+                COALESCE(to_char(due_date, 'YYYY-MM-DD'), ''), ` + taskDueTimeColumn + `, ` + taskDeadlineColumn + `, subject_id, ` + subjectNameColumn + `,
+                parent_id, position, recurrence, next_task_id, ` + dependsOnColumn + `, ` + blockedByColumn + `, ` + taskTagsColumn + `, version, updated_at, created_at`
+
+func scanTask(row rowScanner) (Task, error) {
+    var task Task
+    var subjectID, parentID, nextTaskID sql.NullInt64
+    var dependsOn, blockedBy pq.Int64Array
+    var startedAt, completedAt, deadline sql.NullTime
+    var recurrence, tags []byte
+    err := row.Scan(
+        &task.ID, &task.UserID, &task.Title, &task.Description,
+        &task.Priority, &task.IsCompleted, &task.Status, &startedAt, &completedAt,
+        &task.DueDate, &task.DueTime, &deadline, &subjectID,
+        &task.Subject, &parentID, &task.Position, &recurrence, &nextTaskID, &dependsOn, &blockedBy,
+        &tags, &task.Version, &task.UpdatedAt, &task.CreatedAt,
+    )
+    task.SubjectID = nullIntPtr(subjectID)
+    task.ParentID = nullIntPtr(parentID)
+    task.Recurrence = recurrenceFromJSON(recurrence)
+    task.NextTaskID = nullIntPtr(nextTaskID)
+    task.StartedAt = nullTimePtr(startedAt)
+    task.CompletedAt = nullTimePtr(completedAt)
+    task.Deadline = nullTimePtr(deadline)
+    applyDeadline(&task, time.Now())
+    task.DependsOn = intsFromArray(dependsOn)
+    task.BlockedBy = intsFromArray(blockedBy)
+    task.Blocked = len(task.BlockedBy) > 0
+    task.Tags = tagsFromJSON(tags)
+    if task.IsCompleted {
+        task.Progress = 100
+    }
+    return task, err
+}
+
+// taskInput — полное состояние задачи для обновления.
+type taskInput struct {
+    Title       string          `json:"title"`
+    Description string          `json:"description"`
+    Priority    string          `json:"priority"`
+    IsCompleted bool            `json:"is_completed"`
+    DueDate     string          `json:"due_date"`
+    DueTime     string          `json:"due_time"`
+    DueAt       string          `json:"due_at"`
+    SubjectID   *int            `json:"subject_id"`
+    Subject     string          `json:"subject"`
+    TagIDs      []int           `json:"tag_ids"`
+    Recurrence  *TaskRecurrence `json:"recurrence"`
+}
+
+// getUserIdFromRequest возвращает пользователя, чью сессию проверил authenticate;
+// 0 — запрос без действующей сессии.
+func getUserIdFromRequest(r *http.Request) int {
+    userID, _ := r.Context().Value(sessionUserKey).(int)
+    return userID
+}
+
+// writeBadRequest отвечает 400 с сообщением, сформированным во время проверки данных.
+func writeBadRequest(w http.ResponseWriter, msg string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusBadRequest)
+    json.NewEncoder(w).Encode(map[string]string{"error": msg})
 }
 
 func Register(w http.ResponseWriter, r *http.Request) {
@@ -67,6 +245,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
         Email    string `json:"email"`
         Password string `json:"password"`
         Name     string `json:"name"`
+        Timezone string `json:"timezone"`
     }
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -74,40 +253,24 @@ func Register(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    var existingUser User
-    err := db.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&existingUser.ID)
-    if err != sql.ErrNoRows {
-        http.Error(w, `{"error": "Пользователь с таким email уже существует"}`, http.StatusConflict)
-        return
+    if req.Timezone == "" {
+        req.Timezone = defaultTimezone
     }
-
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-    if err != nil {
-        http.Error(w, `{"error": "Ошибка при создании пользователя"}`, http.StatusInternalServerError)
+    if !validTimezone(req.Timezone) {
+        http.Error(w, `{"error": "Неизвестный часовой пояс"}`, http.StatusBadRequest)
         return
     }
 
-    var userID int
-    err = db.QueryRow(
-        "INSERT INTO users (email, password, name) VALUES ($1, $2, $3) RETURNING id",
-        req.Email, string(hashedPassword), req.Name,
-    ).Scan(&userID)
-    
-    if err != nil {
+    user, err := createUser(req.Email, req.Password, req.Name, req.Timezone, roleStudent)
+    if err == errUserExists {
+        http.Error(w, `{"error": "Пользователь с таким email уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
         http.Error(w, `{"error": "Ошибка при создании пользователя"}`, http.StatusInternalServerError)
         return
     }
     
-    user := User{
-        ID:    userID,
-        Email: req.Email,
-        Name:  req.Name,
-        CreatedAt: time.Now(),
-    }
-    
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(user)
+    writeSession(w, http.StatusCreated, user)
 }
 
 func Login(w http.ResponseWriter, r *http.Request) {
@@ -122,10 +285,12 @@ func Login(w http.ResponseWriter, r *http.Request) {
     }
     
     var user User
+    var locked bool
     err := db.QueryRow(
-        "SELECT id, email, password, name, created_at FROM users WHERE email = $1",
-        req.Email,
-    ).Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.CreatedAt)
+        `SELECT id, email, password, name, timezone, role, totp_enabled_at IS NOT NULL, locked_at IS NOT NULL, created_at
+         FROM users WHERE LOWER(email) = LOWER($1)`,
+        strings.TrimSpace(req.Email),
+    ).Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Timezone, &user.Role, &user.TwoFactorEnabled, &locked, &user.CreatedAt)
     
     if err == sql.ErrNoRows {
         http.Error(w, `{"error": "Неверный email или пароль"}`, http.StatusUnauthorized)
@@ -139,6 +304,84 @@ func Login(w http.ResponseWriter, r *http.Request) {
         http.Error(w, `{"error": "Неверный email или пароль"}`, http.StatusUnauthorized)
         return
     }
+
+    if locked {
+        http.Error(w, `{"error": "Учётная запись заблокирована"}`, http.StatusForbidden)
+        return
+    }
+
+    // С включённой 2FA пароль — только первый шаг: сессию откроет LoginTwoFactor.
+    if user.TwoFactorEnabled {
+        startLoginChallenge(w, user.ID)
+        return
+    }
+    
+    writeSession(w, http.StatusOK, user)
+}
+
+func GetProfile(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+    
+    var user User
+    err := db.QueryRow(
+        "SELECT id, email, name, timezone, role, totp_enabled_at IS NOT NULL, created_at FROM users WHERE id = $1",
+        userID,
+    ).Scan(&user.ID, &user.Email, &user.Name, &user.Timezone, &user.Role, &user.TwoFactorEnabled, &user.CreatedAt)
+    
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(user)
+}
+
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+    
+    var req struct {
+        Name     string `json:"name"`
+        Timezone string `json:"timezone"`
+    }
+    
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+    
+    if req.Timezone != "" && !validTimezone(req.Timezone) {
+        http.Error(w, `{"error": "Неизвестный часовой пояс"}`, http.StatusBadRequest)
+        return
+    }
+    
+    var user User
+    err := db.QueryRow(
+        `UPDATE users 
+         SET name = COALESCE(NULLIF($1, ''), name), timezone = COALESCE(NULLIF($2, ''), timezone) 
+         WHERE id = $3 
+         RETURNING id, email, name, timezone, role, totp_enabled_at IS NOT NULL, created_at`,
+        req.Name, req.Timezone, userID,
+    ).Scan(&user.ID, &user.Email, &user.Name, &user.Timezone, &user.Role, &user.TwoFactorEnabled, &user.CreatedAt)
+    
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления профиля"}`, http.StatusInternalServerError)
+        return
+    }
     
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(user)
@@ -151,14 +394,28 @@ func GetEvents(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    rows, err := db.Query(
-        `SELECT id, user_id, title, description, event_type, subject, location, 
-                event_date, start_time, duration_hours, created_at 
-         FROM events 
-         WHERE user_id = $1 
-         ORDER BY event_date, start_time`,
-        userID,
-    )
+    loc := userLocation(userID)
+    
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения событий"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    query := `SELECT ` + eventColumns("$1") + ` FROM events WHERE ` + visibleEventsScope("$1") + ` AND deleted_at IS NULL`
+    args := []interface{}{userID}
+    if subjectID, ok := subjectFilter(r); ok {
+        args = append(args, subjectID)
+        query += fmt.Sprintf(" AND subject_id = $%d", len(args))
+    }
+    query, args = appendEventTermFilter(query, args, term, loc)
+    query, args = appendTagFilter(query, args, r, "event_tags", "event_id")
+    query += " ORDER BY starts_at"
+    
+    rows, err := db.Query(query, args...)
     
     if err != nil {
         http.Error(w, `{"error": "Ошибка получения событий"}`, http.StatusInternalServerError)
@@ -168,20 +425,14 @@ func GetEvents(w http.ResponseWriter, r *http.Request) {
     
     events := []Event{}
     for rows.Next() {
-        var event Event
-        err := rows.Scan(
-            &event.ID, &event.UserID, &event.Title, &event.Description,
-            &event.EventType, &event.Subject, &event.Location, &event.EventDate,
-            &event.StartTime, &event.DurationHours, &event.CreatedAt,
-        )
+        event, err := scanEvent(rows, loc)
         if err != nil {
             continue
         }
         events = append(events, event)
     }
     
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(events)
+    writeJSONWithETag(w, r, "", events)
 }
 
 func CreateEvent(w http.ResponseWriter, r *http.Request) {
@@ -191,48 +442,28 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    var req struct {
-        Title        string  `json:"title"`
-        Description  string  `json:"description"`
-        EventType    string  `json:"event_type"`
-        Subject      string  `json:"subject"`
-        Location     string  `json:"location"`
-        EventDate    string  `json:"event_date"`
-        StartTime    string  `json:"start_time"`
-        DurationHours float64 `json:"duration_hours"`
-    }
+    var req eventInput
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
         return
     }
+    if msg := validateEventInput(req); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
     
-    var eventID int
-    err := db.QueryRow(
-        `INSERT INTO events (user_id, title, description, event_type, subject, 
-                            location, event_date, start_time, duration_hours) 
-         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
-         RETURNING id`,
-        userID, req.Title, req.Description, req.EventType, req.Subject,
-        req.Location, req.EventDate, req.StartTime, req.DurationHours,
-    ).Scan(&eventID)
-    
+    eventID, err := storeEvent(db, userID, 0, 0, req)
     if err != nil {
-        http.Error(w, `{"error": "Ошибка создания события"}`, http.StatusInternalServerError)
+        writeSaveError(w, err, "Ошибка создания события")
         return
     }
+    recordHistory("event", eventID, userID, "create", nil)
 
-    var event Event
-    err = db.QueryRow(
-        `SELECT id, user_id, title, description, event_type, subject, location, 
-                event_date, start_time, duration_hours, created_at 
-         FROM events WHERE id = $1`,
-        eventID,
-    ).Scan(
-        &event.ID, &event.UserID, &event.Title, &event.Description,
-        &event.EventType, &event.Subject, &event.Location, &event.EventDate,
-        &event.StartTime, &event.DurationHours, &event.CreatedAt,
-    )
+    event, err := scanEvent(db.QueryRow(
+        `SELECT `+eventColumns("$2")+` FROM events WHERE id = $1`,
+        eventID, userID,
+    ), userLocation(userID))
     
     if err != nil {
         http.Error(w, `{"error": "Событие создано, но не получено"}`, http.StatusInternalServerError)
@@ -240,6 +471,7 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
     }
     
     w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("ETag", versionETag(event.Version))
     w.WriteHeader(http.StatusCreated)
     json.NewEncoder(w).Encode(event)
 }
@@ -254,45 +486,47 @@ func UpdateEvent(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     eventID, _ := strconv.Atoi(vars["id"])
     
-    var req struct {
-        Title        string  `json:"title"`
-        Description  string  `json:"description"`
-        EventType    string  `json:"event_type"`
-        Subject      string  `json:"subject"`
-        Location     string  `json:"location"`
-        EventDate    string  `json:"event_date"`
-        StartTime    string  `json:"start_time"`
-        DurationHours float64 `json:"duration_hours"`
-    }
+    var req eventInput
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
         return
     }
-    
-    result, err := db.Exec(
-        `UPDATE events 
-         SET title = $1, description = $2, event_type = $3, subject = $4, 
-             location = $5, event_date = $6, start_time = $7, duration_hours = $8 
-         WHERE id = $9 AND user_id = $10`,
-        req.Title, req.Description, req.EventType, req.Subject,
-        req.Location, req.EventDate, req.StartTime, req.DurationHours,
-        eventID, userID,
-    )
-    
-    if err != nil {
-        http.Error(w, `{"error": "Ошибка обновления события"}`, http.StatusInternalServerError)
+    if msg := validateEventInput(req); msg != "" {
+        writeBadRequest(w, msg)
         return
     }
+
+    version, ok := checkIfMatch(w, r, "event", eventID, userID)
+    if !ok {
+        return
+    }
+    saveEvent(w, userID, eventID, version, req)
+}
+
+// saveEvent записывает полное состояние события и отвечает обновлённым событием.
+// Используется и PUT, и PATCH после наложения патча на текущее состояние.
+func saveEvent(w http.ResponseWriter, userID, eventID, version int, req eventInput) {
+    before := snapshotRow("event", eventID)
+    if _, err := storeEvent(db, userID, eventID, version, req); err != nil {
+        writeSaveError(w, err, "Ошибка обновления события")
+        return
+    }
+    recordHistory("event", eventID, userID, "update", before)
+
+    event, err := scanEvent(db.QueryRow(
+        `SELECT `+eventColumns("$2")+` FROM events WHERE id = $1`,
+        eventID, userID,
+    ), userLocation(userID))
     
-    rowsAffected, _ := result.RowsAffected()
-    if rowsAffected == 0 {
-        http.Error(w, `{"error": "Событие не найдено или нет прав доступа"}`, http.StatusNotFound)
+    if err != nil {
+        http.Error(w, `{"error": "Событие обновлено, но не получено"}`, http.StatusInternalServerError)
         return
     }
     
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{"message": "Событие обновлено"})
+    w.Header().Set("ETag", versionETag(event.Version))
+    json.NewEncoder(w).Encode(event)
 }
 
 func DeleteEvent(w http.ResponseWriter, r *http.Request) {
@@ -305,7 +539,17 @@ func DeleteEvent(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     eventID, _ := strconv.Atoi(vars["id"])
     
-    result, err := db.Exec("DELETE FROM events WHERE id = $1 AND user_id = $2", eventID, userID)
+    version, ok := checkIfMatch(w, r, "event", eventID, userID)
+    if !ok {
+        return
+    }
+    
+    before := snapshotRow("event", eventID)
+    result, err := db.Exec(
+        "UPDATE events SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND "+editableEventsScope("$2")+
+            " AND deleted_at IS NULL AND "+versionCondition("$3"),
+        eventID, userID, version,
+    )
     
     if err != nil {
         http.Error(w, `{"error": "Ошибка удаления события"}`, http.StatusInternalServerError)
@@ -313,13 +557,18 @@ func DeleteEvent(w http.ResponseWriter, r *http.Request) {
     }
     
     rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 && version != 0 {
+        writeVersionConflict(w, currentVersion("event", eventID))
+        return
+    }
     if rowsAffected == 0 {
         http.Error(w, `{"error": "Событие не найдено или нет прав доступа"}`, http.StatusNotFound)
         return
     }
+    recordHistory("event", eventID, userID, "delete", before)
     
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{"message": "Событие удалено"})
+    json.NewEncoder(w).Encode(map[string]string{"message": "Событие перемещено в корзину"})
 }
 
 func GetTasks(w http.ResponseWriter, r *http.Request) {
@@ -329,13 +578,27 @@ func GetTasks(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    rows, err := db.Query(
-        `SELECT id, user_id, title, description, priority, is_completed, due_date, created_at 
-         FROM tasks 
-         WHERE user_id = $1 
-         ORDER BY due_date, priority`,
-        userID,
-    )
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения задач"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    query := `SELECT ` + taskColumns + ` FROM tasks WHERE user_id = $1 AND deleted_at IS NULL`
+    args := []interface{}{userID}
+    if subjectID, ok := subjectFilter(r); ok {
+        args = append(args, subjectID)
+        query += fmt.Sprintf(" AND subject_id = $%d", len(args))
+    }
+    query, args = appendTaskTermFilter(query, args, term)
+    query, args = appendTagFilter(query, args, r, "task_tags", "task_id")
+    query, args = appendDeadlineFilter(query, args, r)
+    query += " ORDER BY " + taskDeadlineColumn + ", priority, position"
+    
+    rows, err := db.Query(query, args...)
     
     if err != nil {
         http.Error(w, `{"error": "Ошибка получения задач"}`, http.StatusInternalServerError)
@@ -345,19 +608,22 @@ func GetTasks(w http.ResponseWriter, r *http.Request) {
     
     tasks := []Task{}
     for rows.Next() {
-        var task Task
-        err := rows.Scan(
-            &task.ID, &task.UserID, &task.Title, &task.Description,
-            &task.Priority, &task.IsCompleted, &task.DueDate, &task.CreatedAt,
-        )
+        task, err := scanTask(rows)
         if err != nil {
             continue
         }
         tasks = append(tasks, task)
     }
     
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(tasks)
+    // Статус отбирается уже после сборки дерева: дерево фильтруется по корням,
+    // и подзадачи с другим статусом остаются в нём и в прогрессе родителя.
+    status := r.URL.Query().Get("status")
+    if r.URL.Query().Get("flat") == "true" {
+        applyTaskProgress(tasks)
+        writeJSONWithETag(w, r, "", filterTasksByStatus(tasks, status))
+        return
+    }
+    writeJSONWithETag(w, r, "", filterTasksByStatus(buildTaskTree(tasks), status))
 }
 
 func CreateTask(w http.ResponseWriter, r *http.Request) {
@@ -368,39 +634,40 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
     }
     
     var req struct {
-        Title       string `json:"title"`
-        Description string `json:"description"`
-        Priority    string `json:"priority"`
-        DueDate     string `json:"due_date"`
+        taskInput
+        ParentID *int `json:"parent_id"`
     }
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
         return
     }
+    if req.Priority == "" {
+        req.Priority = "medium"
+    }
+    if msg := validateTaskInput(req.taskInput); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
     
-    var taskID int
-    err := db.QueryRow(
-        `INSERT INTO tasks (user_id, title, description, priority, due_date) 
-         VALUES ($1, $2, $3, $4, $5) 
-         RETURNING id`,
-        userID, req.Title, req.Description, req.Priority, req.DueDate,
-    ).Scan(&taskID)
-    
+    taskID, _, err := storeTask(db, userID, 0, 0, req.ParentID, req.taskInput)
     if err != nil {
-        http.Error(w, `{"error": "Ошибка создания задачи"}`, http.StatusInternalServerError)
+        writeSaveError(w, err, "Ошибка создания задачи")
         return
     }
+    recordHistory("task", taskID, userID, "create", nil)
     
-    var task Task
-    err = db.QueryRow(
-        `SELECT id, user_id, title, description, priority, is_completed, due_date, created_at 
-         FROM tasks WHERE id = $1`,
+    if req.IsCompleted {
+        if err := spawnNextOccurrence(taskID, userID); err != nil {
+            http.Error(w, `{"error": "Задача создана, но следующее повторение не создано"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+    
+    task, err := scanTask(db.QueryRow(
+        `SELECT `+taskColumns+` FROM tasks WHERE id = $1`,
         taskID,
-    ).Scan(
-        &task.ID, &task.UserID, &task.Title, &task.Description,
-        &task.Priority, &task.IsCompleted, &task.DueDate, &task.CreatedAt,
-    )
+    ))
     
     if err != nil {
         http.Error(w, `{"error": "Задача создана, но не получена"}`, http.StatusInternalServerError)
@@ -408,6 +675,7 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
     }
     
     w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("ETag", versionETag(task.Version))
     w.WriteHeader(http.StatusCreated)
     json.NewEncoder(w).Encode(task)
 }
@@ -422,47 +690,61 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     taskID, _ := strconv.Atoi(vars["id"])
     
-    var req struct {
-        Title       string `json:"title"`
-        Description string `json:"description"`
-        Priority    string `json:"priority"`
-        IsCompleted bool   `json:"is_completed"`
-        DueDate     string `json:"due_date"`
-    }
+    var req taskInput
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
         return
     }
-    
-    result, err := db.Exec(
-        `UPDATE tasks 
-         SET title = $1, description = $2, priority = $3, is_completed = $4, due_date = $5 
-         WHERE id = $6 AND user_id = $7`,
-        req.Title, req.Description, req.Priority, req.IsCompleted, req.DueDate,
-        taskID, userID,
-    )
-    
+    if req.Priority == "" {
+        req.Priority = "medium"
+    }
+    if msg := validateTaskInput(req); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    version, ok := checkIfMatch(w, r, "task", taskID, userID)
+    if !ok {
+        return
+    }
+    saveTask(w, userID, taskID, version, req)
+}
+
+// saveTask записывает полное состояние задачи и отвечает обновлённой задачей.
+// Используется и PUT, и PATCH после наложения патча на текущее состояние.
+func saveTask(w http.ResponseWriter, userID, taskID, version int, req taskInput) {
+    // В транзакции статус, по которому проверяется переход, остаётся заблокированным до записи.
+    tx, err := db.Begin()
     if err != nil {
-        http.Error(w, `{"error": "Ошибка обновления задачи: ` + err.Error() + `"}`, http.StatusInternalServerError)
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
         return
     }
-    
-    rowsAffected, _ := result.RowsAffected()
-    if rowsAffected == 0 {
-        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+    defer tx.Rollback()
+
+    before := snapshotRow("task", taskID)
+    _, ownerID, err := storeTask(tx, userID, taskID, version, nil, req)
+    if err != nil {
+        writeSaveError(w, err, "Ошибка обновления задачи")
+        return
+    }
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
         return
     }
+    recordHistory("task", taskID, userID, "update", before)
 
-    var task Task
-    err = db.QueryRow(
-        `SELECT id, user_id, title, description, priority, is_completed, due_date, created_at 
-         FROM tasks WHERE id = $1 AND user_id = $2`,
-        taskID, userID,
-    ).Scan(
-        &task.ID, &task.UserID, &task.Title, &task.Description,
-        &task.Priority, &task.IsCompleted, &task.DueDate, &task.CreatedAt,
-    )
+    if req.IsCompleted {
+        if err := spawnNextOccurrence(taskID, ownerID); err != nil {
+            http.Error(w, `{"error": "Задача обновлена, но следующее повторение не создано"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    task, err := scanTask(db.QueryRow(
+        `SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`,
+        taskID, ownerID,
+    ))
     
     if err != nil {
         http.Error(w, `{"error": "Задача обновлена, но не получена"}`, http.StatusInternalServerError)
@@ -470,6 +752,7 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
     }
     
     w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("ETag", versionETag(task.Version))
     json.NewEncoder(w).Encode(task)
 }
 
@@ -485,6 +768,8 @@ func ToggleTaskCompletion(w http.ResponseWriter, r *http.Request) {
     
     var req struct {
         IsCompleted bool `json:"is_completed"`
+        Cascade     bool `json:"cascade"`
+        Force       bool `json:"force"`
     }
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -492,11 +777,70 @@ func ToggleTaskCompletion(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    result, err := db.Exec(
-        `UPDATE tasks 
-         SET is_completed = $1 
-         WHERE id = $2 AND user_id = $3`,
-        req.IsCompleted, taskID, userID,
+    version, ok := checkIfMatch(w, r, "task", taskID, userID)
+    if !ok {
+        return
+    }
+    
+    ownerID, err := taskOwner(db, taskID, userID, "edit")
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    // Меняется несколько строк, поэтому версия и статус задачи сверяются под блокировкой.
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+    
+    if matches, err := lockVersion(tx, "task", taskID, version); err != nil {
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
+        return
+    } else if !matches {
+        writeVersionConflict(w, currentVersion("task", taskID))
+        return
+    }
+    
+    current, err := taskStatus(tx, taskID, ownerID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    // При cascade статус переносится на вложенные подзадачи, кроме заблокированных
+    // и отменённых: из этих статусов отметка о выполнении не переводит.
+    scope := "SELECT $1::int"
+    if req.Cascade {
+        scope = `SELECT s.id FROM subtree s JOIN tasks c ON c.id = s.id
+                 WHERE s.id = $1 OR c.status NOT IN ('blocked', 'cancelled')`
+    }
+    
+    status := completionStatus(current, req.IsCompleted)
+    err = checkTaskTransition(tx, taskID, ownerID, current, status, scope, req.Force)
+    if transition, ok := err.(*transitionError); ok {
+        writeTransitionError(w, transition)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки зависимостей"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    before := snapshotRow("task", taskID)
+    result, err := tx.Exec(
+        taskSubtreeCTE+`
+        UPDATE tasks 
+        SET `+taskStatusAssignments("CASE WHEN id = $1 THEN $4::varchar WHEN $3 THEN 'done' WHEN status = 'done' THEN 'todo' ELSE status END")+`
+        WHERE user_id = $2 AND id IN (`+scope+`) AND deleted_at IS NULL`,
+        taskID, ownerID, req.IsCompleted, status,
     )
     
     if err != nil {
@@ -509,16 +853,20 @@ func ToggleTaskCompletion(w http.ResponseWriter, r *http.Request) {
         http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
         return
     }
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка обновления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    recordHistory("task", taskID, userID, "toggle", before)
 
-    var task Task
-    err = db.QueryRow(
-        `SELECT id, user_id, title, description, priority, is_completed, due_date, created_at 
-         FROM tasks WHERE id = $1 AND user_id = $2`,
-        taskID, userID,
-    ).Scan(
-        &task.ID, &task.UserID, &task.Title, &task.Description,
-        &task.Priority, &task.IsCompleted, &task.DueDate, &task.CreatedAt,
-    )
+    if req.IsCompleted {
+        if err := spawnNextOccurrence(taskID, ownerID); err != nil {
+            http.Error(w, `{"error": "Задача выполнена, но следующее повторение не создано"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    task, err := loadTaskTree(taskID, ownerID)
     
     if err != nil {
         http.Error(w, `{"error": "Статус задачи обновлен, но не получен"}`, http.StatusInternalServerError)
@@ -526,6 +874,7 @@ func ToggleTaskCompletion(w http.ResponseWriter, r *http.Request) {
     }
     
     w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("ETag", versionETag(task.Version))
     json.NewEncoder(w).Encode(task)
 }
 
@@ -539,7 +888,43 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     taskID, _ := strconv.Atoi(vars["id"])
     
-    result, err := db.Exec("DELETE FROM tasks WHERE id = $1 AND user_id = $2", taskID, userID)
+    version, ok := checkIfMatch(w, r, "task", taskID, userID)
+    if !ok {
+        return
+    }
+    
+    ownerID, err := taskOwner(db, taskID, userID, "edit")
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    // Подзадачи уходят в корзину владельца вместе с задачей и с тем же временем удаления,
+    // чтобы восстанавливаться вместе с ней.
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+    
+    if matches, err := lockVersion(tx, "task", taskID, version); err != nil {
+        http.Error(w, `{"error": "Ошибка удаления задачи"}`, http.StatusInternalServerError)
+        return
+    } else if !matches {
+        writeVersionConflict(w, currentVersion("task", taskID))
+        return
+    }
+    
+    before := snapshotRow("task", taskID)
+    result, err := tx.Exec(
+        taskSubtreeCTE+`
+        UPDATE tasks SET deleted_at = CURRENT_TIMESTAMP WHERE id IN (SELECT id FROM subtree)`,
+        taskID, ownerID,
+    )
     
     if err != nil {
         http.Error(w, `{"error": "Ошибка удаления задачи"}`, http.StatusInternalServerError)
@@ -551,9 +936,14 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) {
         http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
         return
     }
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка удаления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    recordHistory("task", taskID, userID, "delete", before)
     
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{"message": "Задача удалена"})
+    json.NewEncoder(w).Encode(map[string]string{"message": "Задача перемещена в корзину"})
 }
 
 func GetSchedule(w http.ResponseWriter, r *http.Request) {
@@ -563,15 +953,23 @@ func GetSchedule(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    rows, err := db.Query(
-        `SELECT id, title, event_type, subject, location, 
-                event_date, start_time, duration_hours 
-         FROM events 
-         WHERE user_id = $1 AND event_date >= CURRENT_DATE 
-         ORDER BY event_date, start_time 
-         LIMIT 10`,
-        userID,
-    )
+    loc := userLocation(userID)
+    today := dateOnly(time.Now(), loc)
+    
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения расписания"}`, http.StatusInternalServerError)
+        return
+    }
+    
+    query := `SELECT ` + eventColumns("$1") + ` FROM events WHERE ` + visibleEventsScope("$1") + ` AND deleted_at IS NULL AND starts_at >= $2`
+    query, args := appendEventTermFilter(query, []interface{}{userID, today}, term, loc)
+    query += " ORDER BY starts_at LIMIT 10"
+    
+    rows, err := db.Query(query, args...)
     
     if err != nil {
         http.Error(w, `{"error": "Ошибка получения расписания"}`, http.StatusInternalServerError)
@@ -580,27 +978,38 @@ func GetSchedule(w http.ResponseWriter, r *http.Request) {
     defer rows.Close()
     
     type ScheduleItem struct {
-        ID           int     `json:"id"`
-        Title        string  `json:"title"`
-        EventType    string  `json:"event_type"`
-        Subject      string  `json:"subject"`
-        Location     string  `json:"location"`
-        EventDate    string  `json:"event_date"`
-        StartTime    string  `json:"start_time"`
-        DurationHours float64 `json:"duration_hours"`
+        ID           int       `json:"id"`
+        Title        string    `json:"title"`
+        EventType    string    `json:"event_type"`
+        Subject      string    `json:"subject"`
+        Location     string    `json:"location"`
+        Teacher      string    `json:"teacher"`
+        Room         string    `json:"room"`
+        StartsAt     time.Time `json:"starts_at"`
+        EventDate    string    `json:"event_date"`
+        StartTime    string    `json:"start_time"`
+        DurationHours float64   `json:"duration_hours"`
     }
     
     schedule := []ScheduleItem{}
     for rows.Next() {
-        var item ScheduleItem
-        err := rows.Scan(
-            &item.ID, &item.Title, &item.EventType, &item.Subject,
-            &item.Location, &item.EventDate, &item.StartTime, &item.DurationHours,
-        )
+        event, err := scanEvent(rows, loc)
         if err != nil {
             continue
         }
-        schedule = append(schedule, item)
+        schedule = append(schedule, ScheduleItem{
+            ID:            event.ID,
+            Title:         event.Title,
+            EventType:     event.EventType,
+            Subject:       event.Subject,
+            Location:      event.Location,
+            Teacher:       event.Teacher,
+            Room:          event.Room,
+            StartsAt:      event.StartsAt,
+            EventDate:     event.EventDate,
+            StartTime:     event.StartTime,
+            DurationHours: event.DurationHours,
+        })
     }
     
     w.Header().Set("Content-Type", "application/json")
@@ -614,21 +1023,18 @@ func GetWeekSchedule(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    now := time.Now()
-    weekday := int(now.Weekday())
-    if weekday == 0 {
-        weekday = 7 
-    }
+    loc := userLocation(userID)
+    today := dateOnly(time.Now(), loc)
+    weekday := isoWeekday(today)
     
-    startOfWeek := now.AddDate(0, 0, -weekday+1).Format("2006-01-02")
-    endOfWeek := now.AddDate(0, 0, 7-weekday).Format("2006-01-02")
+    startOfWeek := today.AddDate(0, 0, -weekday+1)
+    endOfWeek := today.AddDate(0, 0, 8-weekday)
     
     rows, err := db.Query(
-        `SELECT id, title, event_type, subject, location, 
-                event_date, start_time, duration_hours 
+        `SELECT `+eventColumns("$1")+`
          FROM events 
-         WHERE user_id = $1 AND event_date BETWEEN $2 AND $3 
-         ORDER BY event_date, start_time`,
+         WHERE `+visibleEventsScope("$1")+` AND deleted_at IS NULL AND starts_at >= $2 AND starts_at < $3 
+         ORDER BY starts_at`,
         userID, startOfWeek, endOfWeek,
     )
     
@@ -640,29 +1046,25 @@ func GetWeekSchedule(w http.ResponseWriter, r *http.Request) {
     
     weekSchedule := make(map[string][]interface{})
     for rows.Next() {
-        var id int
-        var title, eventType, subject, location, eventDate, startTime string
-        var durationHours float64
-        
-        err := rows.Scan(
-            &id, &title, &eventType, &subject, &location,
-            &eventDate, &startTime, &durationHours,
-        )
+        event, err := scanEvent(rows, loc)
         if err != nil {
             continue
         }
         
-        event := map[string]interface{}{
-            "id": id,
-            "title": title,
-            "event_type": eventType,
-            "subject": subject,
-            "location": location,
-            "start_time": startTime,
-            "duration_hours": durationHours,
+        item := map[string]interface{}{
+            "id": event.ID,
+            "title": event.Title,
+            "event_type": event.EventType,
+            "subject": event.Subject,
+            "location": event.Location,
+            "teacher": event.Teacher,
+            "room": event.Room,
+            "starts_at": event.StartsAt,
+            "start_time": event.StartTime,
+            "duration_hours": event.DurationHours,
         }
         
-        weekSchedule[eventDate] = append(weekSchedule[eventDate], event)
+        weekSchedule[event.EventDate] = append(weekSchedule[event.EventDate], item)
     }
     
     w.Header().Set("Content-Type", "application/json")
@@ -677,17 +1079,34 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
     }
     
     type Stats struct {
+        TermID         *int    `json:"term_id"`
         TotalEvents    int     `json:"total_events"`
         TotalTasks     int     `json:"total_tasks"`
         CompletedTasks int     `json:"completed_tasks"`
+        OverdueTasks   int     `json:"overdue_tasks"`
         StudyHours     float64 `json:"study_hours"`
     }
     
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+    
     var stats Stats
+    if term != nil {
+        stats.TermID = &term.ID
+    }
+    
+    eventWhere, eventArgs := appendEventTermFilter("user_id = $1 AND deleted_at IS NULL", []interface{}{userID}, term, userLocation(userID))
+    taskWhere, taskArgs := appendTaskTermFilter("user_id = $1 AND deleted_at IS NULL", []interface{}{userID}, term)
 
-    err := db.QueryRow(
-        "SELECT COUNT(*) FROM events WHERE user_id = $1",
-        userID,
+    err = db.QueryRow(
+        "SELECT COUNT(*) FROM events WHERE "+eventWhere,
+        eventArgs...,
     ).Scan(&stats.TotalEvents)
     
     if err != nil {
@@ -696,8 +1115,8 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
     }
  
     err = db.QueryRow(
-        "SELECT COUNT(*) FROM tasks WHERE user_id = $1",
-        userID,
+        "SELECT COUNT(*) FROM tasks WHERE "+taskWhere,
+        taskArgs...,
     ).Scan(&stats.TotalTasks)
     
     if err != nil {
@@ -706,18 +1125,28 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
     }
 
     err = db.QueryRow(
-        "SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND is_completed = true",
-        userID,
+        "SELECT COUNT(*) FROM tasks WHERE "+taskWhere+" AND is_completed = true",
+        taskArgs...,
     ).Scan(&stats.CompletedTasks)
     
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+
+    err = db.QueryRow(
+        "SELECT COUNT(*) FROM tasks WHERE "+taskWhere+" AND "+taskOverdueCondition,
+        taskArgs...,
+    ).Scan(&stats.OverdueTasks)
+    
     if err != nil {
         http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
         return
     }
  
     err = db.QueryRow(
-        "SELECT COALESCE(SUM(duration_hours), 0) FROM events WHERE user_id = $1",
-        userID,
+        "SELECT COALESCE(SUM(duration_hours), 0) FROM events WHERE "+eventWhere,
+        eventArgs...,
     ).Scan(&stats.StudyHours)
     
     if err != nil {
@@ -731,6 +1160,10 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 
 func CheckAuth(w http.ResponseWriter, r *http.Request) {
     userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
         "status": "authenticated",
@@ -0,0 +1,402 @@
+package main
+
+import (
+    "bytes"
+    "database/sql"
+    "encoding/json"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+// historyTables сопоставляет тип объекта истории с его таблицей.
+var historyTables = map[string]string{
+    "event": "events",
+    "task":  "tasks",
+}
+
+type HistoryChange struct {
+    From interface{} `json:"from"`
+    To   interface{} `json:"to"`
+}
+
+type HistoryEntry struct {
+    ID        int64                    `json:"id"`
+    Action    string                   `json:"action"`
+    ActorID   *int                     `json:"actor_id"`
+    Actor     string                   `json:"actor"`
+    Changes   map[string]HistoryChange `json:"changes"`
+    Before    json.RawMessage          `json:"before"`
+    After     json.RawMessage          `json:"after"`
+    CreatedAt time.Time                `json:"created_at"`
+}
+
+// snapshotRow возвращает строку таблицы целиком в виде JSON; nil, если строки нет.
+func snapshotRow(entityType string, id int) json.RawMessage {
+    var snapshot []byte
+    err := db.QueryRow(
+        "SELECT to_jsonb(t) FROM "+historyTables[entityType]+" t WHERE id = $1",
+        id,
+    ).Scan(&snapshot)
+    if err != nil {
+        return nil
+    }
+    return snapshot
+}
+
+// recordHistory дописывает в журнал изменение объекта: before снимается до операции,
+// состояние после неё снимается здесь. Ошибка журнала не отменяет саму операцию.
+func recordHistory(entityType string, id, actorID int, action string, before json.RawMessage) {
+    after := snapshotRow(entityType, id)
+
+    var beforeValue, afterValue interface{}
+    if before != nil {
+        beforeValue = []byte(before)
+    }
+    if after != nil {
+        afterValue = []byte(after)
+    }
+
+    _, err := db.Exec(
+        `INSERT INTO change_history (entity_type, entity_id, actor_id, action, before, after)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+        entityType, id, actorID, action, beforeValue, afterValue,
+    )
+    if err != nil {
+        log.Printf("Ошибка записи истории %s %d: %v", entityType, id, err)
+    }
+}
+
+// diffSnapshots перечисляет поля, значения которых различаются в двух снимках.
+func diffSnapshots(before, after json.RawMessage) map[string]HistoryChange {
+    var from, to map[string]json.RawMessage
+    json.Unmarshal(before, &from)
+    json.Unmarshal(after, &to)
+
+    keys := make(map[string]bool)
+    for key := range from {
+        keys[key] = true
+    }
+    for key := range to {
+        keys[key] = true
+    }
+
+    changes := make(map[string]HistoryChange)
+    for key := range keys {
+        if bytes.Equal(from[key], to[key]) {
+            continue
+        }
+        var change HistoryChange
+        json.Unmarshal(from[key], &change.From)
+        json.Unmarshal(to[key], &change.To)
+        changes[key] = change
+    }
+    return changes
+}
+
+// historyScope — условие на объекты, журнал которых пользователь из $2 может читать,
+// а при edit — откатывать. Права те же, что на чтение и изменение самих объектов.
+func historyScope(entityType string, edit bool) string {
+    if entityType == "event" {
+        if edit {
+            return editableEventsScope("$2")
+        }
+        return `(` + visibleEventsScope("$2") + ` OR ` + sharedScope("event", "$2", false) + `)`
+    }
+    if edit {
+        return editableTasksScope("$2")
+    }
+    return `(user_id = $2 OR ` + sharedScope("task", "$2", false) + `)`
+}
+
+// historyOwner возвращает владельца объекта, если historyScope пускает к нему пользователя.
+// Объекты в корзине не исключаются: их журнал остаётся доступным.
+func historyOwner(entityType string, id, userID int, edit bool) (int, error) {
+    var ownerID int
+    err := db.QueryRow(
+        "SELECT user_id FROM "+historyTables[entityType]+" WHERE id = $1 AND "+historyScope(entityType, edit),
+        id, userID,
+    ).Scan(&ownerID)
+    return ownerID, err
+}
+
+func writeHistory(w http.ResponseWriter, r *http.Request, entityType string) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    id, _ := strconv.Atoi(vars["id"])
+
+    if _, err := historyOwner(entityType, id, userID, false); err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Объект не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения истории"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rows, err := db.Query(
+        `SELECT h.id, h.action, h.actor_id, COALESCE(u.name, ''), h.before, h.after, h.created_at
+         FROM change_history h LEFT JOIN users u ON u.id = h.actor_id
+         WHERE h.entity_type = $1 AND h.entity_id = $2
+         ORDER BY h.id DESC`,
+        entityType, id,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения истории"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    entries := []HistoryEntry{}
+    for rows.Next() {
+        var entry HistoryEntry
+        var actorID sql.NullInt64
+        var before, after []byte
+        err := rows.Scan(&entry.ID, &entry.Action, &actorID, &entry.Actor, &before, &after, &entry.CreatedAt)
+        if err != nil {
+            continue
+        }
+        entry.ActorID = nullIntPtr(actorID)
+        entry.Before = before
+        entry.After = after
+        entry.Changes = diffSnapshots(before, after)
+        entries = append(entries, entry)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(entries)
+}
+
+func GetEventHistory(w http.ResponseWriter, r *http.Request) {
+    writeHistory(w, r, "event")
+}
+
+func GetTaskHistory(w http.ResponseWriter, r *http.Request) {
+    writeHistory(w, r, "task")
+}
+
+// loadHistoryVersion возвращает состояние объекта после записи журнала historyID.
+func loadHistoryVersion(entityType string, id int, historyID int64) (json.RawMessage, error) {
+    var after []byte
+    err := db.QueryRow(
+        `SELECT after FROM change_history
+         WHERE id = $1 AND entity_type = $2 AND entity_id = $3 AND after IS NOT NULL`,
+        historyID, entityType, id,
+    ).Scan(&after)
+    return after, err
+}
+
+// RevertEvent возвращает событию содержимое версии из журнала.
+// Ссылки на удалённые с тех пор предметы, преподавателей и аудитории сбрасываются.
+func RevertEvent(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    eventID, _ := strconv.Atoi(vars["id"])
+    historyID, _ := strconv.ParseInt(vars["historyId"], 10, 64)
+
+    ownerID, err := historyOwner("event", eventID, userID, true)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Событие не найдено или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    version, err := loadHistoryVersion("event", eventID, historyID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Версия не найдена"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    var target struct {
+        RoomID        *int      `json:"room_id"`
+        StartsAt      time.Time `json:"starts_at"`
+        DurationHours float64   `json:"duration_hours"`
+    }
+    json.Unmarshal(version, &target)
+    if target.RoomID != nil {
+        conflicts, err := findRoomConflicts(db, ownerID, *target.RoomID, eventID, target.StartsAt, target.DurationHours)
+        if err != nil {
+            http.Error(w, `{"error": "Ошибка проверки занятости аудитории"}`, http.StatusInternalServerError)
+            return
+        }
+        if len(conflicts) > 0 {
+            writeRoomConflicts(w, conflicts)
+            return
+        }
+    }
+
+    before := snapshotRow("event", eventID)
+    result, err := db.Exec(
+        `UPDATE events e
+         SET title = v.title, description = v.description, event_type = v.event_type,
+             subject_id = (SELECT id FROM subjects WHERE id = v.subject_id),
+             location = v.location,
+             teacher_id = (SELECT id FROM teachers WHERE id = v.teacher_id),
+             room_id = (SELECT id FROM rooms WHERE id = v.room_id),
+             starts_at = v.starts_at, duration_hours = v.duration_hours
+         FROM jsonb_populate_record(NULL::events, $1) v
+         WHERE e.id = $2 AND e.user_id = $3 AND e.deleted_at IS NULL`,
+        []byte(version), eventID, ownerID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Событие находится в корзине"}`, http.StatusConflict)
+        return
+    }
+    recordHistory("event", eventID, userID, "revert", before)
+
+    event, err := scanEvent(db.QueryRow(
+        `SELECT `+eventColumns("$2")+` FROM events WHERE id = $1`,
+        eventID, userID,
+    ), userLocation(userID))
+    if err != nil {
+        http.Error(w, `{"error": "Версия восстановлена, но событие не получено"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(event)
+}
+
+// RevertTask возвращает задаче содержимое версии из журнала. Статус меняется по тем же
+// правилам переходов и зависимостей, что и в UpdateTaskStatus; даты начала и выполнения
+// пересчитываются, а не копируются из версии. Подзадачи и зависимости не затрагиваются.
+func RevertTask(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+    historyID, _ := strconv.ParseInt(vars["historyId"], 10, 64)
+
+    ownerID, err := historyOwner("task", taskID, userID, true)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    version, err := loadHistoryVersion("task", taskID, historyID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Версия не найдена"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления версии"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    // Статус читается под блокировкой строки в той же транзакции, что и запись.
+    current, err := taskStatus(tx, taskID, ownerID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача находится в корзине"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    var target struct {
+        Status string `json:"status"`
+    }
+    json.Unmarshal(version, &target)
+    if _, ok := taskTransitions[target.Status]; !ok {
+        target.Status = current
+    }
+
+    err = checkTaskTransition(tx, taskID, ownerID, current, target.Status, "SELECT $1::int", false)
+    if transition, ok := err.(*transitionError); ok {
+        writeTransitionError(w, transition)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки зависимостей"}`, http.StatusInternalServerError)
+        return
+    }
+
+    before := snapshotRow("task", taskID)
+    result, err := tx.Exec(
+        `UPDATE tasks t
+         SET title = v.title, description = v.description, priority = v.priority,
+             due_date = v.due_date, due_at = v.due_at,
+             subject_id = (SELECT id FROM subjects WHERE id = v.subject_id),
+             recurrence = v.recurrence
+         FROM jsonb_populate_record(NULL::tasks, $1) v
+         WHERE t.id = $2 AND t.user_id = $3 AND t.deleted_at IS NULL`,
+        []byte(version), taskID, ownerID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Задача находится в корзине"}`, http.StatusConflict)
+        return
+    }
+
+    // Статус отдельным запросом: в UPDATE ... FROM выше started_at и completed_at
+    // были бы неоднозначны между задачей и версией.
+    if _, err := tx.Exec(
+        `UPDATE tasks SET `+taskStatusAssignments("$1::varchar")+` WHERE id = $2`,
+        target.Status, taskID,
+    ); err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления версии"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления версии"}`, http.StatusInternalServerError)
+        return
+    }
+    recordHistory("task", taskID, userID, "revert", before)
+
+    if target.Status == "done" {
+        if err := spawnNextOccurrence(taskID, ownerID); err != nil {
+            http.Error(w, `{"error": "Версия восстановлена, но следующее повторение не создано"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    task, err := loadTaskTree(taskID, ownerID)
+    if err != nil {
+        http.Error(w, `{"error": "Версия восстановлена, но задача не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(task)
+}
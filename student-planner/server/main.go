@@ -1,28 +1,50 @@
 package main
 
 import (
+    "fmt"
     "log"
     "net/http"
     "os"
+    "time"
+    _ "time/tzdata"
     
     "github.com/gorilla/handlers"
     "github.com/gorilla/mux"
 )
 
 func main() {
-    db, err := InitDB()
+    if err := runCommand(loadConfig(), os.Args[1:]); err != nil {
+        log.Fatal(err)
+    }
+}
+
+// serve запускает HTTP API — подкоманда по умолчанию.
+func serve(cfg Config) error {
+    db, err := InitDB(cfg)
     if err != nil {
-        log.Fatal("Ошибка инициализации БД:", err)
+        return fmt.Errorf("ошибка инициализации БД: %v", err)
     }
     defer db.Close()
     
+    trashRetention = cfg.TrashRetention
+    startTrashPurger(trashRetention, time.Hour)
+    promoteConfiguredAdmin(cfg)
+    if cfg.DemoAccount {
+        ensureDemoAccount()
+        if cfg.DemoResetInterval > 0 {
+            startDemoReset(cfg.DemoResetInterval)
+        }
+    }
+    
+    limiter := newAuthLimiter(cfg)
     r := mux.NewRouter()
     
     r.Use(func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-            w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID")
+            w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+            w.Header().Set("Access-Control-Expose-Headers", "ETag, Retry-After")
             w.Header().Set("Access-Control-Allow-Credentials", "true")
             
             if r.Method == "OPTIONS" {
@@ -33,25 +55,129 @@ func main() {
             next.ServeHTTP(w, r)
         })
     })
+    r.Use(authenticate)
 
-    r.HandleFunc("/api/register", Register).Methods("POST", "OPTIONS")
-    r.HandleFunc("/api/login", Login).Methods("POST", "OPTIONS")
+    r.Handle("/api/register", limiter.wrap("register", Register)).Methods("POST", "OPTIONS")
+    r.Handle("/api/login", limiter.wrap("login", Login)).Methods("POST", "OPTIONS")
+    r.Handle("/api/login/2fa", limiter.wrap("login", LoginTwoFactor)).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/logout", Logout).Methods("POST", "OPTIONS")
+
+    r.HandleFunc("/api/profile", GetProfile).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/profile", UpdateProfile).Methods("PUT", "OPTIONS")
 
     r.HandleFunc("/api/events", GetEvents).Methods("GET", "OPTIONS")
     r.HandleFunc("/api/events", CreateEvent).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/events/bulk", BulkEvents).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/events/{id}", GetEvent).Methods("GET", "OPTIONS")
     r.HandleFunc("/api/events/{id}", UpdateEvent).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/events/{id}", PatchEvent).Methods("PATCH", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/shares", GetItemShares).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/shares", ShareItem).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/shares/{userId}", RevokeItemShare).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/history", GetEventHistory).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/history/{historyId}/revert", RevertEvent).Methods("POST", "OPTIONS")
     r.HandleFunc("/api/events/{id}", DeleteEvent).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/attendance", MarkAttendance).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/events/{id}/attendance", ClearAttendance).Methods("DELETE", "OPTIONS")
 
     r.HandleFunc("/api/tasks", GetTasks).Methods("GET", "OPTIONS")
     r.HandleFunc("/api/tasks", CreateTask).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/tasks/bulk", BulkTasks).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/tasks/board", GetTaskBoard).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}", GetTask).Methods("GET", "OPTIONS")
     r.HandleFunc("/api/tasks/{id}", UpdateTask).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}", PatchTask).Methods("PATCH", "OPTIONS")
     r.HandleFunc("/api/tasks/{id}/toggle", ToggleTaskCompletion).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/status", UpdateTaskStatus).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/subtasks/order", ReorderSubtasks).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/dependencies", GetTaskDependencies).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/dependencies", AddTaskDependency).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/dependencies/{dependsOnId}", DeleteTaskDependency).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/shares", GetItemShares).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/shares", ShareItem).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/shares/{userId}", RevokeItemShare).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/history", GetTaskHistory).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/tasks/{id}/history/{historyId}/revert", RevertTask).Methods("POST", "OPTIONS")
     r.HandleFunc("/api/tasks/{id}", DeleteTask).Methods("DELETE", "OPTIONS")
  
     r.HandleFunc("/api/schedule", GetSchedule).Methods("GET", "OPTIONS")
     r.HandleFunc("/api/schedule/week", GetWeekSchedule).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/schedule/day", GetDaySchedule).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/schedule/month", GetMonthSchedule).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/schedule/agenda", GetAgendaSchedule).Methods("GET", "OPTIONS")
+
+    r.HandleFunc("/api/terms", GetTerms).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/terms", CreateTerm).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/terms/{id}", UpdateTerm).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/terms/{id}", DeleteTerm).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/terms/{id}/activate", ActivateTerm).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/terms/{id}/archive", ArchiveTerm).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/terms/{id}/unarchive", UnarchiveTerm).Methods("PUT", "OPTIONS")
+
+    r.HandleFunc("/api/subjects", GetSubjects).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/subjects", CreateSubject).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/subjects/{id}", UpdateSubject).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/subjects/{id}", DeleteSubject).Methods("DELETE", "OPTIONS")
+
+    r.HandleFunc("/api/teachers", GetTeachers).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/teachers", CreateTeacher).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/teachers/{id}", UpdateTeacher).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/teachers/{id}", DeleteTeacher).Methods("DELETE", "OPTIONS")
 
+    r.HandleFunc("/api/rooms", GetRooms).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/rooms", CreateRoom).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/rooms/{id}", UpdateRoom).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/rooms/{id}", DeleteRoom).Methods("DELETE", "OPTIONS")
+
+    r.HandleFunc("/api/grades", GetGrades).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/grades", CreateGrade).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/grades/{id}", UpdateGrade).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/grades/{id}", DeleteGrade).Methods("DELETE", "OPTIONS")
+
+    r.HandleFunc("/api/tags", GetTags).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/tags", CreateTag).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/tags/{id}", UpdateTag).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/tags/{id}", DeleteTag).Methods("DELETE", "OPTIONS")
+    
+    r.HandleFunc("/api/shared", GetSharedWithMe).Methods("GET", "OPTIONS")
+    
+    r.HandleFunc("/api/groups", GetGroups).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/groups", CreateGroup).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/groups/join", JoinGroup).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/groups/invites", GetGroupInvites).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/groups/invites/{inviteId}", RespondGroupInvite).Methods("POST", "DELETE", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}", GetGroup).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}", UpdateGroup).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}", DeleteGroup).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/invite-code", RegenerateInviteCode).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/invites", InviteToGroup).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/members/{userId}", UpdateGroupMember).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/members/{userId}", RemoveGroupMember).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/events", GetGroupEvents).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/attendance", GetGroupAttendance).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/groups/{id}/events/{eventId}/override", SetGroupEventOverride).Methods("PUT", "DELETE", "OPTIONS")
+    
+    r.HandleFunc("/api/trash", GetTrash).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/trash/{type}/{id}/restore", RestoreTrashItem).Methods("POST", "OPTIONS")
+    
     r.HandleFunc("/api/stats", GetStats).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/stats/grades", GetGradeStats).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/stats/attendance", GetAttendanceStats).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/stats/subjects", GetSubjectStats).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/stats/tags", GetTagStats).Methods("GET", "OPTIONS")
+
+    r.HandleFunc("/api/2fa", GetTwoFactorStatus).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/2fa/setup", SetupTwoFactor).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/2fa/enable", EnableTwoFactor).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/2fa/disable", DisableTwoFactor).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/2fa/recovery-codes", RegenerateRecoveryCodes).Methods("POST", "OPTIONS")
+
+    r.HandleFunc("/api/admin/users", AdminGetUsers).Methods("GET", "OPTIONS")
+    r.HandleFunc("/api/admin/users/{id}/lock", AdminLockUser).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/admin/users/{id}/unlock", AdminUnlockUser).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/admin/users/{id}/role", AdminSetUserRole).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/api/admin/users/{id}/reset-password", AdminResetPassword).Methods("POST", "OPTIONS")
+    r.HandleFunc("/api/admin/stats", AdminGetStats).Methods("GET", "OPTIONS")
 
     r.HandleFunc("/api/check-auth", CheckAuth).Methods("GET", "OPTIONS")
  
@@ -66,8 +192,8 @@ func main() {
             "message": "Демо режим", 
             "instructions": "Зарегистрируйтесь или войдите в систему",
             "test_account": {
-                "email": "test@example.com",
-                "password": "test123"
+                "email": "` + demoEmail + `",
+                "password": "` + demoPassword + `"
             }
         }`))
     }).Methods("GET", "OPTIONS")
@@ -77,12 +203,12 @@ func main() {
         w.Write([]byte(`{"status": "ok", "version": "1.0.0"}`))
     }).Methods("GET", "OPTIONS")
     
-    port := "8080"
+    port := cfg.Port
     log.Printf("Сервер запущен на http://localhost:%s", port)
     log.Printf("API доступен по адресу http://localhost:%s/api", port)
     log.Printf("Тест: http://localhost:%s/api/test", port)
     log.Printf("Демо: http://localhost:%s/api/demo", port)
     
     loggedRouter := handlers.LoggingHandler(os.Stdout, r)
-    log.Fatal(http.ListenAndServe(":"+port, loggedRouter))
+    return http.ListenAndServe(":"+port, loggedRouter)
 }
\ No newline at end of file
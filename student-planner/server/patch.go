@@ -0,0 +1,263 @@
+package main
+
+import (
+    "bytes"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+var taskPriorities = map[string]bool{
+    "low":    true,
+    "medium": true,
+    "high":   true,
+}
+
+// mergePatch накладывает JSON Merge Patch (RFC 7396) на документ target.
+func mergePatch(target, patch json.RawMessage) json.RawMessage {
+    trimmed := bytes.TrimSpace(patch)
+    if len(trimmed) == 0 || trimmed[0] != '{' {
+        return patch
+    }
+
+    var patchObj map[string]json.RawMessage
+    json.Unmarshal(trimmed, &patchObj)
+
+    var targetObj map[string]json.RawMessage
+    if err := json.Unmarshal(target, &targetObj); err != nil || targetObj == nil {
+        targetObj = make(map[string]json.RawMessage)
+    }
+
+    for key, value := range patchObj {
+        if isJSONNull(value) {
+            delete(targetObj, key)
+            continue
+        }
+        targetObj[key] = mergePatch(targetObj[key], value)
+    }
+
+    merged, _ := json.Marshal(targetObj)
+    return merged
+}
+
+func isJSONNull(value json.RawMessage) bool {
+    return string(bytes.TrimSpace(value)) == "null"
+}
+
+// readMergePatch читает тело PATCH-запроса; патч верхнего уровня обязан быть объектом.
+func readMergePatch(r *http.Request) (json.RawMessage, map[string]json.RawMessage, error) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+        return nil, nil, fmt.Errorf("патч должен быть JSON-объектом")
+    }
+    return body, fields, nil
+}
+
+// checkRequiredFields запрещает сбрасывать через null поля, без которых объект не существует.
+func checkRequiredFields(fields map[string]json.RawMessage, required ...string) string {
+    for _, name := range required {
+        if value, ok := fields[name]; ok && isJSONNull(value) {
+            return fmt.Sprintf("Поле %s не может быть пустым", name)
+        }
+    }
+    return ""
+}
+
+func PatchEvent(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    eventID, _ := strconv.Atoi(vars["id"])
+
+    patch, fields, err := readMergePatch(r)
+    if err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if msg := checkRequiredFields(fields, "title", "event_type", "starts_at", "event_date", "start_time", "duration_hours"); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    event, err := scanEvent(db.QueryRow(
+        `SELECT `+eventColumns("$2")+` FROM events WHERE id = $1 AND `+editableEventsScope("$2")+` AND deleted_at IS NULL`,
+        eventID, userID,
+    ), userLocation(userID))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Событие не найдено или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения события"}`, http.StatusInternalServerError)
+        return
+    }
+
+    current := eventInput{
+        Title:         event.Title,
+        Description:   event.Description,
+        EventType:     event.EventType,
+        SubjectID:     event.SubjectID,
+        Location:      event.Location,
+        TeacherID:     event.TeacherID,
+        RoomID:        event.RoomID,
+        StartsAt:      event.StartsAt.Format(time.RFC3339),
+        DurationHours: event.DurationHours,
+    }
+
+    // Дата и время могут меняться по отдельности в локальном поясе пользователя.
+    _, hasStartsAt := fields["starts_at"]
+    _, hasDate := fields["event_date"]
+    _, hasTime := fields["start_time"]
+    if !hasStartsAt && (hasDate || hasTime) {
+        current.StartsAt = ""
+        current.EventDate = event.EventDate
+        current.StartTime = event.StartTime
+    }
+
+    // Предмет по названию заменяет текущую ссылку на предмет.
+    if _, ok := fields["subject"]; ok {
+        if _, ok := fields["subject_id"]; !ok {
+            current.SubjectID = nil
+        }
+    }
+
+    document, _ := json.Marshal(current)
+    var req eventInput
+    if err := json.Unmarshal(mergePatch(document, patch), &req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if _, ok := fields["title"]; ok && strings.TrimSpace(req.Title) == "" {
+        writeBadRequest(w, "Введите название события")
+        return
+    }
+    if _, ok := fields["event_type"]; ok && strings.TrimSpace(req.EventType) == "" {
+        writeBadRequest(w, "Укажите тип события")
+        return
+    }
+    if _, ok := fields["duration_hours"]; ok && req.DurationHours <= 0 {
+        writeBadRequest(w, "Продолжительность должна быть положительной")
+        return
+    }
+
+    // Теги меняются, только если переданы: null снимает все теги.
+    if value, ok := fields["tag_ids"]; ok && isJSONNull(value) {
+        req.TagIDs = []int{}
+    }
+
+    version, ok := checkIfMatch(w, r, "event", eventID, userID)
+    if !ok {
+        return
+    }
+    // Без If-Match патч всё равно накладывался на прочитанную версию: если её успели
+    // изменить, запись не пройдёт и клиент получит 412 вместо потери чужой правки.
+    if version == 0 {
+        version = event.Version
+    }
+    saveEvent(w, userID, eventID, version, req)
+}
+
+func PatchTask(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+
+    patch, fields, err := readMergePatch(r)
+    if err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if msg := checkRequiredFields(fields, "title", "priority", "is_completed"); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    task, err := scanTask(db.QueryRow(
+        `SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND `+editableTasksScope("$2")+` AND deleted_at IS NULL`,
+        taskID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения задачи"}`, http.StatusInternalServerError)
+        return
+    }
+
+    current := taskInput{
+        Title:       task.Title,
+        Description: task.Description,
+        Priority:    task.Priority,
+        IsCompleted: task.IsCompleted,
+        DueDate:     task.DueDate,
+        DueTime:     task.DueTime,
+        SubjectID:   task.SubjectID,
+        Recurrence:  task.Recurrence,
+    }
+
+    // due_at задаёт срок целиком, а снятие даты снимает и время.
+    if _, ok := fields["due_at"]; ok {
+        current.DueDate, current.DueTime = "", ""
+    }
+    if value, ok := fields["due_date"]; ok && isJSONNull(value) {
+        current.DueTime = ""
+    }
+
+    if _, ok := fields["subject"]; ok {
+        if _, ok := fields["subject_id"]; !ok {
+            current.SubjectID = nil
+        }
+    }
+
+    document, _ := json.Marshal(current)
+    var req taskInput
+    if err := json.Unmarshal(mergePatch(document, patch), &req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if _, ok := fields["title"]; ok && strings.TrimSpace(req.Title) == "" {
+        writeBadRequest(w, "Введите название задачи")
+        return
+    }
+    if _, ok := fields["priority"]; ok && !taskPriorities[req.Priority] {
+        writeBadRequest(w, "Приоритет должен быть low, medium или high")
+        return
+    }
+
+    if value, ok := fields["tag_ids"]; ok && isJSONNull(value) {
+        req.TagIDs = []int{}
+    }
+
+    version, ok := checkIfMatch(w, r, "task", taskID, userID)
+    if !ok {
+        return
+    }
+    if version == 0 {
+        version = task.Version
+    }
+    saveTask(w, userID, taskID, version, req)
+}
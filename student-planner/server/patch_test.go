@@ -0,0 +1,48 @@
+package main
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+func TestMergePatch(t *testing.T) {
+    // Примеры из приложения A RFC 7396.
+    cases := []struct {
+        name   string
+        target string
+        patch  string
+        want   string
+    }{
+        {"replace value", `{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+        {"add member", `{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+        {"null deletes member", `{"a": "b"}`, `{"a": null}`, `{}`},
+        {"null deletes only named member", `{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+        {"null for missing member", `{"a": "b"}`, `{"c": null}`, `{"a": "b"}`},
+        {"array replaces array", `{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+        {"value replaces array", `{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+        {"nested merge", `{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+        {"nested delete", `{"a": {"b": "c", "d": "e"}, "f": 1}`, `{"a": {"d": null}}`, `{"a": {"b": "c"}, "f": 1}`},
+        {"arrays are not merged", `{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+        {"object replaces scalar", `{"a": "foo"}`, `{"a": {"b": "c"}}`, `{"a": {"b": "c"}}`},
+        {"nested null inside new member", `{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+        {"object patch on non-object target", `["a", "b"]`, `{"a": "b"}`, `{"a": "b"}`},
+        {"array patch replaces target", `{"a": "b"}`, `["c"]`, `["c"]`},
+        {"scalar patch replaces target", `{"a": "foo"}`, `"bar"`, `"bar"`},
+        {"null patch replaces target", `{"a": "foo"}`, `null`, `null`},
+        {"empty patch keeps target", `{"a": "b"}`, `{}`, `{"a": "b"}`},
+    }
+    for _, c := range cases {
+        got := mergePatch(json.RawMessage(c.target), json.RawMessage(c.patch))
+
+        var gotValue, wantValue interface{}
+        if err := json.Unmarshal(got, &gotValue); err != nil {
+            t.Errorf("%s: mergePatch = %s, not valid JSON: %v", c.name, got, err)
+            continue
+        }
+        json.Unmarshal([]byte(c.want), &wantValue)
+        if !reflect.DeepEqual(gotValue, wantValue) {
+            t.Errorf("%s: mergePatch = %s, want %s", c.name, got, c.want)
+        }
+    }
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+    "net/http"
+)
+
+const (
+    roleStudent     = "student"
+    roleGroupLeader = "group_leader"
+    roleAdmin       = "admin"
+)
+
+var userRoles = map[string]bool{
+    roleStudent:     true,
+    roleGroupLeader: true,
+    roleAdmin:       true,
+}
+
+// Действия, доступность которых зависит от роли пользователя. Доступ к собственным
+// данным ролью не ограничивается и проверяется самими обработчиками.
+const (
+    actionCreateGroup         = "create_group"
+    actionViewGroupAttendance = "view_group_attendance"
+    actionManageUsers         = "manage_users"
+    actionViewSystemStats     = "view_system_stats"
+)
+
+var rolePolicy = map[string]map[string]bool{
+    roleStudent: {
+        actionCreateGroup: true,
+    },
+    roleGroupLeader: {
+        actionCreateGroup:         true,
+        actionViewGroupAttendance: true,
+    },
+    roleAdmin: {
+        actionCreateGroup:         true,
+        actionViewGroupAttendance: true,
+        actionManageUsers:         true,
+        actionViewSystemStats:     true,
+    },
+}
+
+// userAccess возвращает роль пользователя и заблокирована ли его учётная запись.
+func userAccess(userID int) (string, bool, error) {
+    var role string
+    var locked bool
+    err := db.QueryRow(
+        "SELECT role, locked_at IS NOT NULL FROM users WHERE id = $1",
+        userID,
+    ).Scan(&role, &locked)
+    return role, locked, err
+}
+
+// can сообщает, разрешает ли роль пользователя действие action.
+func can(userID int, action string) bool {
+    role, locked, err := userAccess(userID)
+    if err != nil || locked {
+        return false
+    }
+    return rolePolicy[role][action]
+}
+
+// authorize проверяет, что пользователь вошёл и его роль разрешает action.
+// Возвращает id пользователя или 0, если ответ уже записан.
+func authorize(w http.ResponseWriter, r *http.Request, action string) int {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return 0
+    }
+
+    if !can(userID, action) {
+        http.Error(w, `{"error": "Недостаточно прав"}`, http.StatusForbidden)
+        return 0
+    }
+    return userID
+}
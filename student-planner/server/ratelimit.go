@@ -0,0 +1,437 @@
+package main
+
+import (
+    "bytes"
+    "database/sql"
+    "encoding/json"
+    "io"
+    "log"
+    "math"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// rateLimit — параметры token bucket: не больше burst запросов подряд,
+// затем один запрос за каждые every.
+type rateLimit struct {
+    burst float64
+    every time.Duration
+}
+
+// spend пополняет бакет за прошедшее время и списывает токен. Если токена нет,
+// возвращает, сколько ждать до следующего.
+func (l rateLimit) spend(tokens float64, updated, now time.Time) (float64, time.Duration) {
+    elapsed := math.Max(0, float64(now.Sub(updated)))
+    tokens = math.Min(l.burst, tokens+elapsed/float64(l.every))
+    if tokens >= 1 {
+        return tokens - 1, 0
+    }
+    return tokens, time.Duration((1 - tokens) * float64(l.every))
+}
+
+// Лимиты на IP и на аккаунт (email из тела запроса) для входа и регистрации.
+var authLimits = map[string]struct{ ip, account rateLimit }{
+    "login": {
+        ip:      rateLimit{burst: 20, every: 6 * time.Second},
+        account: rateLimit{burst: 5, every: time.Minute},
+    },
+    "register": {
+        ip:      rateLimit{burst: 5, every: 12 * time.Minute},
+        account: rateLimit{burst: 3, every: 20 * time.Minute},
+    },
+}
+
+// Прогрессивная блокировка: после loginLockoutThreshold неудачных входов подряд аккаунт
+// блокируется на loginLockoutBase, каждая следующая ошибка удваивает срок до loginLockoutMax.
+// Счётчик забывается через loginFailureWindow без ошибок или после успешного входа.
+const (
+    loginLockoutThreshold = 5
+    loginLockoutBase      = time.Minute
+    loginLockoutMax       = time.Hour
+    loginFailureWindow    = 24 * time.Hour
+)
+
+func loginLockout(failures int) time.Duration {
+    if failures < loginLockoutThreshold {
+        return 0
+    }
+    lockout := loginLockoutBase
+    for i := loginLockoutThreshold; i < failures && lockout < loginLockoutMax; i++ {
+        lockout *= 2
+    }
+    if lockout > loginLockoutMax {
+        lockout = loginLockoutMax
+    }
+    return lockout
+}
+
+// rateLimitStore хранит бакеты и счётчики неудачных входов.
+type rateLimitStore interface {
+    // take списывает токен из бакета key; 0 означает, что запрос разрешён.
+    take(key string, limit rateLimit, now time.Time) (time.Duration, error)
+    lockedUntil(key string) (time.Time, error)
+    // recordFailure увеличивает счётчик ошибок входа и возвращает его новое значение.
+    recordFailure(key string, now time.Time) (int, error)
+    lock(key string, until time.Time) error
+    clearFailures(key string) error
+}
+
+type memoryBucket struct {
+    tokens  float64
+    updated time.Time
+}
+
+type memoryFailures struct {
+    count       int
+    last        time.Time
+    lockedUntil time.Time
+}
+
+// memoryRateLimitStore хранит состояние в памяти процесса — подходит для одного экземпляра сервера.
+type memoryRateLimitStore struct {
+    mu       sync.Mutex
+    buckets  map[string]*memoryBucket
+    failures map[string]*memoryFailures
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+    return &memoryRateLimitStore{
+        buckets:  map[string]*memoryBucket{},
+        failures: map[string]*memoryFailures{},
+    }
+}
+
+// memoryStorePruneSize — после скольких бакетов или счётчиков ошибок из памяти
+// выбрасываются давно неактивные.
+const memoryStorePruneSize = 10000
+
+func (s *memoryRateLimitStore) take(key string, limit rateLimit, now time.Time) (time.Duration, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if len(s.buckets) > memoryStorePruneSize {
+        for k, bucket := range s.buckets {
+            if now.Sub(bucket.updated) > loginFailureWindow {
+                delete(s.buckets, k)
+            }
+        }
+    }
+
+    bucket, ok := s.buckets[key]
+    if !ok {
+        bucket = &memoryBucket{tokens: limit.burst, updated: now}
+        s.buckets[key] = bucket
+    }
+
+    var wait time.Duration
+    bucket.tokens, wait = limit.spend(bucket.tokens, bucket.updated, now)
+    bucket.updated = now
+    return wait, nil
+}
+
+func (s *memoryRateLimitStore) lockedUntil(key string) (time.Time, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if failures, ok := s.failures[key]; ok {
+        return failures.lockedUntil, nil
+    }
+    return time.Time{}, nil
+}
+
+func (s *memoryRateLimitStore) recordFailure(key string, now time.Time) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // Как и в postgres-хранилище, забываются счётчики без ошибок дольше окна
+    // и без действующей блокировки.
+    if len(s.failures) > memoryStorePruneSize {
+        for k, failures := range s.failures {
+            if now.Sub(failures.last) > loginFailureWindow && !failures.lockedUntil.After(now) {
+                delete(s.failures, k)
+            }
+        }
+    }
+
+    failures, ok := s.failures[key]
+    if !ok || now.Sub(failures.last) > loginFailureWindow {
+        failures = &memoryFailures{}
+        s.failures[key] = failures
+    }
+    failures.count++
+    failures.last = now
+    return failures.count, nil
+}
+
+func (s *memoryRateLimitStore) lock(key string, until time.Time) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if failures, ok := s.failures[key]; ok {
+        failures.lockedUntil = until
+    }
+    return nil
+}
+
+func (s *memoryRateLimitStore) clearFailures(key string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    delete(s.failures, key)
+    return nil
+}
+
+// postgresRateLimitStore хранит состояние в таблицах rate_limit_buckets и login_failures,
+// чтобы лимиты были общими для нескольких экземпляров сервера.
+type postgresRateLimitStore struct{}
+
+func (postgresRateLimitStore) take(key string, limit rateLimit, now time.Time) (time.Duration, error) {
+    tx, err := db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    tokens, updated := limit.burst, now
+    err = tx.QueryRow(
+        "SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE",
+        key,
+    ).Scan(&tokens, &updated)
+    if err != nil && err != sql.ErrNoRows {
+        return 0, err
+    }
+
+    tokens, wait := limit.spend(tokens, updated, now)
+    _, err = tx.Exec(
+        `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3)
+         ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = EXCLUDED.updated_at`,
+        key, tokens, now,
+    )
+    if err != nil {
+        return 0, err
+    }
+    return wait, tx.Commit()
+}
+
+func (postgresRateLimitStore) lockedUntil(key string) (time.Time, error) {
+    var until sql.NullTime
+    err := db.QueryRow("SELECT locked_until FROM login_failures WHERE key = $1", key).Scan(&until)
+    if err == sql.ErrNoRows {
+        return time.Time{}, nil
+    }
+    return until.Time, err
+}
+
+func (postgresRateLimitStore) recordFailure(key string, now time.Time) (int, error) {
+    var failures int
+    err := db.QueryRow(
+        `INSERT INTO login_failures (key, failures, last_failure_at) VALUES ($1, 1, $2)
+         ON CONFLICT (key) DO UPDATE SET
+             failures = CASE WHEN login_failures.last_failure_at < $2 - $3::float8 * INTERVAL '1 second'
+                             THEN 1 ELSE login_failures.failures + 1 END,
+             last_failure_at = EXCLUDED.last_failure_at
+         RETURNING failures`,
+        key, now, loginFailureWindow.Seconds(),
+    ).Scan(&failures)
+    return failures, err
+}
+
+func (postgresRateLimitStore) lock(key string, until time.Time) error {
+    _, err := db.Exec("UPDATE login_failures SET locked_until = $2 WHERE key = $1", key, until)
+    return err
+}
+
+func (postgresRateLimitStore) clearFailures(key string) error {
+    _, err := db.Exec("DELETE FROM login_failures WHERE key = $1", key)
+    return err
+}
+
+// prune удаляет бакеты и счётчики, не менявшиеся дольше loginFailureWindow.
+func (postgresRateLimitStore) prune(now time.Time) error {
+    cutoff := now.Add(-loginFailureWindow)
+    if _, err := db.Exec("DELETE FROM rate_limit_buckets WHERE updated_at < $1", cutoff); err != nil {
+        return err
+    }
+    _, err := db.Exec(
+        "DELETE FROM login_failures WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)",
+        cutoff, now,
+    )
+    return err
+}
+
+// newRateLimitStore выбирает хранилище по настройке RATE_LIMIT_STORE.
+// Для postgres запускается ежечасная очистка устаревших строк.
+func newRateLimitStore(kind string) rateLimitStore {
+    if kind != "postgres" {
+        return newMemoryRateLimitStore()
+    }
+
+    store := postgresRateLimitStore{}
+    go func() {
+        ticker := time.NewTicker(time.Hour)
+        defer ticker.Stop()
+
+        for range ticker.C {
+            if err := store.prune(time.Now()); err != nil {
+                log.Printf("Ошибка очистки лимитов запросов: %v", err)
+            }
+        }
+    }()
+    return store
+}
+
+// authLimiter ограничивает частоту входа и регистрации и блокирует вход
+// после серии неудачных попыток.
+type authLimiter struct {
+    store      rateLimitStore
+    now        func() time.Time
+    trustProxy bool
+    // challengeEmail находит email пользователя по токену второго шага входа,
+    // чтобы подбор кода 2FA упирался в те же лимиты и блокировку, что и подбор пароля.
+    challengeEmail func(challenge string) string
+}
+
+func newAuthLimiter(cfg Config) *authLimiter {
+    return &authLimiter{
+        store:          newRateLimitStore(cfg.RateLimitStore),
+        now:            time.Now,
+        trustProxy:     cfg.TrustProxy,
+        challengeEmail: loginChallengeEmail,
+    }
+}
+
+// clientIP — адрес клиента; X-Forwarded-For учитывается только за доверенным прокси.
+func (l *authLimiter) clientIP(r *http.Request) string {
+    if l.trustProxy {
+        if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+            return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+        }
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+// requestEmail достаёт из JSON-тела email аккаунта, оставляя тело нетронутым для обработчика.
+// У второго шага входа email в теле нет, и аккаунт определяется по токену challenge.
+func (l *authLimiter) requestEmail(r *http.Request) string {
+    body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+    r.Body.Close()
+    r.Body = io.NopCloser(bytes.NewReader(body))
+    if err != nil {
+        return ""
+    }
+
+    var req struct {
+        Email     string `json:"email"`
+        Challenge string `json:"challenge"`
+    }
+    json.Unmarshal(body, &req)
+    if req.Email == "" && req.Challenge != "" && l.challengeEmail != nil {
+        req.Email = l.challengeEmail(req.Challenge)
+    }
+    return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+    seconds := int(math.Ceil(wait.Seconds()))
+    if seconds < 1 {
+        seconds = 1
+    }
+    w.Header().Set("Retry-After", strconv.Itoa(seconds))
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusTooManyRequests)
+    json.NewEncoder(w).Encode(map[string]interface{}{"error": msg, "retry_after": seconds})
+}
+
+// statusRecorder запоминает код ответа, чтобы после входа узнать, удался ли он.
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+    s.status = status
+    s.ResponseWriter.WriteHeader(status)
+}
+
+// wrap ограничивает обработчик next лимитами действия action ("login" или "register").
+// Успешный вход (200) сбрасывает счётчик ошибок, отказ (401) увеличивает его; выдача
+// токена второго шага (202) счётчик не трогает — вход ещё не завершён.
+// Ошибки хранилища не блокируют вход: лучше пропустить запрос, чем закрыть доступ всем.
+func (l *authLimiter) wrap(action string, next http.HandlerFunc) http.Handler {
+    limits := authLimits[action]
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        now := l.now()
+        ip := l.clientIP(r)
+        email := l.requestEmail(r)
+        accountKey := action + ":account:" + email
+
+        if action == "login" && email != "" {
+            until, err := l.store.lockedUntil(accountKey)
+            if err != nil {
+                log.Printf("Ошибка проверки блокировки входа: %v", err)
+            } else if until.After(now) {
+                writeTooManyRequests(w, until.Sub(now), "Слишком много неудачных попыток входа, повторите позже")
+                return
+            }
+        }
+
+        buckets := map[string]rateLimit{action + ":ip:" + ip: limits.ip}
+        if email != "" {
+            buckets[accountKey] = limits.account
+        }
+        for key, limit := range buckets {
+            wait, err := l.store.take(key, limit, now)
+            if err != nil {
+                log.Printf("Ошибка ограничения частоты запросов: %v", err)
+                continue
+            }
+            if wait > 0 {
+                writeTooManyRequests(w, wait, "Слишком много запросов, повторите позже")
+                return
+            }
+        }
+
+        recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+        next(recorder, r)
+
+        if action != "login" || email == "" {
+            return
+        }
+        switch recorder.status {
+        case http.StatusOK:
+            if err := l.store.clearFailures(accountKey); err != nil {
+                log.Printf("Ошибка сброса счётчика входов: %v", err)
+            }
+        case http.StatusUnauthorized:
+            l.loginFailed(accountKey, email, ip, now)
+        }
+    })
+}
+
+// loginFailed учитывает неудачный вход и при достижении порога блокирует аккаунт.
+func (l *authLimiter) loginFailed(key, email, ip string, now time.Time) {
+    failures, err := l.store.recordFailure(key, now)
+    if err != nil {
+        log.Printf("Ошибка учёта неудачного входа: %v", err)
+        return
+    }
+
+    lockout := loginLockout(failures)
+    if lockout == 0 {
+        return
+    }
+    if err := l.store.lock(key, now.Add(lockout)); err != nil {
+        log.Printf("Ошибка блокировки входа: %v", err)
+        return
+    }
+    log.Printf("Вход для %s заблокирован на %s после %d неудачных попыток (IP %s)", email, lockout, failures, ip)
+}
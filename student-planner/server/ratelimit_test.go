@@ -0,0 +1,239 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRateLimitSpend(t *testing.T) {
+    limit := rateLimit{burst: 5, every: time.Minute}
+    start := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+
+    cases := []struct {
+        name       string
+        tokens     float64
+        elapsed    time.Duration
+        wantTokens float64
+        wantWait   time.Duration
+    }{
+        {"full bucket", 5, 0, 4, 0},
+        {"last token", 1, 0, 0, 0},
+        {"empty bucket waits a full period", 0, 0, 0, time.Minute},
+        {"partly refilled", 0, 15 * time.Second, 0.25, 45 * time.Second},
+        {"refilled by elapsed time", 0, time.Minute, 0, 0},
+        {"refill is capped at burst", 3, time.Hour, 4, 0},
+        {"clock going backwards does not refill", 0, -time.Hour, 0, time.Minute},
+    }
+    for _, c := range cases {
+        tokens, wait := limit.spend(c.tokens, start, start.Add(c.elapsed))
+        if tokens != c.wantTokens || wait != c.wantWait {
+            t.Errorf("%s: spend = (%v, %v), want (%v, %v)", c.name, tokens, wait, c.wantTokens, c.wantWait)
+        }
+    }
+}
+
+func TestLoginLockout(t *testing.T) {
+    cases := []struct {
+        failures int
+        want     time.Duration
+    }{
+        {0, 0},
+        {loginLockoutThreshold - 1, 0},
+        {loginLockoutThreshold, time.Minute},
+        {loginLockoutThreshold + 1, 2 * time.Minute},
+        {loginLockoutThreshold + 2, 4 * time.Minute},
+        {loginLockoutThreshold + 3, 8 * time.Minute},
+        {loginLockoutThreshold + 6, loginLockoutMax},
+        {loginLockoutThreshold + 100, loginLockoutMax},
+    }
+    for _, c := range cases {
+        if got := loginLockout(c.failures); got != c.want {
+            t.Errorf("loginLockout(%d) = %v, want %v", c.failures, got, c.want)
+        }
+    }
+}
+
+func TestMemoryStorePrunesFailures(t *testing.T) {
+    store := newMemoryRateLimitStore()
+    start := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+    for i := 0; i <= memoryStorePruneSize; i++ {
+        store.recordFailure(fmt.Sprintf("login:account:user%d@example.com", i), start)
+    }
+    store.lock("login:account:user0@example.com", start.Add(48*time.Hour))
+
+    later := start.Add(loginFailureWindow + time.Minute)
+    store.recordFailure("login:account:fresh@example.com", later)
+
+    // Остаются только свежий счётчик и аккаунт, блокировка которого ещё действует.
+    if len(store.failures) != 2 {
+        t.Fatalf("failures after prune = %d, want 2", len(store.failures))
+    }
+    if until, _ := store.lockedUntil("login:account:user0@example.com"); !until.Equal(start.Add(48 * time.Hour)) {
+        t.Errorf("active lockout was pruned")
+    }
+}
+
+// testLimiter — authLimiter с хранилищем в памяти и часами, которые двигает тест.
+type testLimiter struct {
+    *authLimiter
+    clock time.Time
+}
+
+func newTestLimiter() *testLimiter {
+    l := &testLimiter{clock: time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)}
+    l.authLimiter = &authLimiter{
+        store: newMemoryRateLimitStore(),
+        now:   func() time.Time { return l.clock },
+    }
+    return l
+}
+
+// fakeLogin пускает только с паролем "secret".
+func fakeLogin(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Password string `json:"password"`
+    }
+    json.NewDecoder(r.Body).Decode(&req)
+    if req.Password != "secret" {
+        http.Error(w, `{"error": "Неверный email или пароль"}`, http.StatusUnauthorized)
+        return
+    }
+    w.WriteHeader(http.StatusOK)
+}
+
+func postJSON(h http.Handler, path, body string) *httptest.ResponseRecorder {
+    r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, r)
+    return w
+}
+
+func TestAuthLimiterLoginLockout(t *testing.T) {
+    l := newTestLimiter()
+    h := l.wrap("login", fakeLogin)
+    wrong := `{"email": "Student@Example.com", "password": "wrong"}`
+    right := `{"email": "student@example.com", "password": "secret"}`
+
+    for i := 1; i <= loginLockoutThreshold; i++ {
+        if w := postJSON(h, "/api/login", wrong); w.Code != http.StatusUnauthorized {
+            t.Fatalf("attempt %d: status %d, want 401", i, w.Code)
+        }
+    }
+
+    // Пятая ошибка подряд блокирует аккаунт на минуту, даже с верным паролем.
+    w := postJSON(h, "/api/login", right)
+    if w.Code != http.StatusTooManyRequests {
+        t.Fatalf("after lockout: status %d, want 429", w.Code)
+    }
+    if got := w.Header().Get("Retry-After"); got != "60" {
+        t.Errorf("Retry-After = %s, want 60", got)
+    }
+
+    // Следующая ошибка после блокировки удваивает срок.
+    l.clock = l.clock.Add(61 * time.Second)
+    if w := postJSON(h, "/api/login", wrong); w.Code != http.StatusUnauthorized {
+        t.Fatalf("after first lockout: status %d, want 401", w.Code)
+    }
+    w = postJSON(h, "/api/login", right)
+    if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "120" {
+        t.Fatalf("second lockout: status %d, Retry-After %s, want 429 and 120", w.Code, w.Header().Get("Retry-After"))
+    }
+
+    // Успешный вход сбрасывает счётчик: одна новая ошибка не блокирует.
+    // Ждём дольше блокировки, чтобы бакет аккаунта успел наполниться.
+    l.clock = l.clock.Add(10 * time.Minute)
+    if w := postJSON(h, "/api/login", right); w.Code != http.StatusOK {
+        t.Fatalf("login after lockout: status %d, want 200", w.Code)
+    }
+    if w := postJSON(h, "/api/login", wrong); w.Code != http.StatusUnauthorized {
+        t.Fatalf("failure after success: status %d, want 401", w.Code)
+    }
+    if w := postJSON(h, "/api/login", right); w.Code != http.StatusOK {
+        t.Fatalf("login after reset: status %d, want 200", w.Code)
+    }
+}
+
+func TestAuthLimiterTwoFactorLockout(t *testing.T) {
+    l := newTestLimiter()
+    l.challengeEmail = func(challenge string) string {
+        if challenge == "challenge" {
+            return "Student@Example.com"
+        }
+        return ""
+    }
+    // С включённой 2FA верный пароль выдаёт токен второго шага (202), вход завершает код.
+    password := l.wrap("login", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusAccepted)
+    })
+    code := l.wrap("login", func(w http.ResponseWriter, r *http.Request) {
+        var req struct {
+            Code string `json:"code"`
+        }
+        json.NewDecoder(r.Body).Decode(&req)
+        if req.Code != "123456" {
+            http.Error(w, `{"error": "Неверный код подтверждения"}`, http.StatusUnauthorized)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+    })
+    wrong := `{"challenge": "challenge", "code": "000000"}`
+    right := `{"challenge": "challenge", "code": "123456"}`
+
+    for i := 1; i < loginLockoutThreshold; i++ {
+        if w := postJSON(code, "/api/login/2fa", wrong); w.Code != http.StatusUnauthorized {
+            t.Fatalf("code attempt %d: status %d, want 401", i, w.Code)
+        }
+    }
+
+    // Повторный ввод пароля не сбрасывает счётчик ошибок второго шага.
+    l.clock = l.clock.Add(2 * time.Minute)
+    if w := postJSON(password, "/api/login", `{"email": "student@example.com", "password": "secret"}`); w.Code != http.StatusAccepted {
+        t.Fatalf("password step: status %d, want 202", w.Code)
+    }
+    if w := postJSON(code, "/api/login/2fa", wrong); w.Code != http.StatusUnauthorized {
+        t.Fatalf("last code attempt: status %d, want 401", w.Code)
+    }
+
+    // Ошибки кода блокируют аккаунт так же, как ошибки пароля, и для обоих шагов.
+    w := postJSON(code, "/api/login/2fa", right)
+    if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+        t.Fatalf("code after lockout: status %d, Retry-After %s, want 429 and 60", w.Code, w.Header().Get("Retry-After"))
+    }
+    if w := postJSON(password, "/api/login", `{"email": "student@example.com", "password": "secret"}`); w.Code != http.StatusTooManyRequests {
+        t.Fatalf("password after lockout: status %d, want 429", w.Code)
+    }
+}
+
+func TestAuthLimiterIPBucket(t *testing.T) {
+    l := newTestLimiter()
+    h := l.wrap("register", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusCreated)
+    })
+    limit := authLimits["register"].ip
+
+    // Разные email не обходят лимит на IP.
+    for i := 0; i < int(limit.burst); i++ {
+        body := fmt.Sprintf(`{"email": "user%d@example.com"}`, i)
+        if w := postJSON(h, "/api/register", body); w.Code != http.StatusCreated {
+            t.Fatalf("register %d: status %d, want 201", i, w.Code)
+        }
+    }
+    w := postJSON(h, "/api/register", `{"email": "another@example.com"}`)
+    if w.Code != http.StatusTooManyRequests {
+        t.Fatalf("over the IP limit: status %d, want 429", w.Code)
+    }
+    if got, want := w.Header().Get("Retry-After"), fmt.Sprint(int(limit.every.Seconds())); got != want {
+        t.Errorf("Retry-After = %s, want %s", got, want)
+    }
+
+    // Через один период бакет пополняется на один запрос.
+    l.clock = l.clock.Add(limit.every)
+    if w := postJSON(h, "/api/register", `{"email": "later@example.com"}`); w.Code != http.StatusCreated {
+        t.Fatalf("after refill: status %d, want 201", w.Code)
+    }
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "time"
+)
+
+// TaskRecurrence — правило повторения задачи. Режим interval сдвигает срок на Interval
+// дней или недель от срока предыдущего экземпляра, after_completion — от дня выполнения,
+// weekdays переносит срок на ближайший следующий день недели из Weekdays (1 — понедельник).
+type TaskRecurrence struct {
+    Mode     string `json:"mode"`
+    Interval int    `json:"interval,omitempty"`
+    Unit     string `json:"unit,omitempty"`
+    Weekdays []int  `json:"weekdays,omitempty"`
+    Until    string `json:"until,omitempty"`
+}
+
+func (rule *TaskRecurrence) validate() string {
+    switch rule.Mode {
+    case "interval", "after_completion":
+        if rule.Interval == 0 {
+            rule.Interval = 1
+        }
+        if rule.Interval < 0 {
+            return "Интервал повторения должен быть положительным"
+        }
+        if rule.Unit == "" {
+            rule.Unit = "day"
+        }
+        if rule.Unit != "day" && rule.Unit != "week" {
+            return "Единица интервала должна быть day или week"
+        }
+        rule.Weekdays = nil
+    case "weekdays":
+        if len(rule.Weekdays) == 0 {
+            return "Укажите дни недели для повторения"
+        }
+        for _, day := range rule.Weekdays {
+            if day < 1 || day > 7 {
+                return "Дни недели задаются числами от 1 до 7"
+            }
+        }
+        rule.Interval = 0
+        rule.Unit = ""
+    default:
+        return "Режим повторения должен быть interval, weekdays или after_completion"
+    }
+
+    if rule.Until != "" {
+        if _, err := time.Parse("2006-01-02", rule.Until); err != nil {
+            return "Неверная дата окончания повторений"
+        }
+    }
+    return ""
+}
+
+// next возвращает срок следующего экземпляра по сроку текущего и дню выполнения.
+// false означает, что повторения закончились.
+func (rule TaskRecurrence) next(due, completed time.Time) (time.Time, bool) {
+    days := rule.Interval
+    if rule.Unit == "week" {
+        days *= 7
+    }
+
+    var next time.Time
+    switch rule.Mode {
+    case "interval":
+        next = due.AddDate(0, 0, days)
+    case "after_completion":
+        next = completed.AddDate(0, 0, days)
+    case "weekdays":
+        allowed := make(map[int]bool)
+        for _, day := range rule.Weekdays {
+            allowed[day] = true
+        }
+        for i := 1; i <= 7; i++ {
+            if day := due.AddDate(0, 0, i); allowed[isoWeekday(day)] {
+                next = day
+                break
+            }
+        }
+    }
+
+    if next.IsZero() {
+        return next, false
+    }
+    if rule.Until != "" {
+        until, _ := time.ParseInLocation("2006-01-02", rule.Until, next.Location())
+        if next.After(until) {
+            return next, false
+        }
+    }
+    return next, true
+}
+
+// recurrenceValue готовит правило для записи в JSONB; nil превращается в NULL.
+func recurrenceValue(rule *TaskRecurrence) interface{} {
+    if rule == nil {
+        return nil
+    }
+    data, _ := json.Marshal(rule)
+    return data
+}
+
+func recurrenceFromJSON(data []byte) *TaskRecurrence {
+    if len(data) == 0 {
+        return nil
+    }
+    var rule TaskRecurrence
+    if err := json.Unmarshal(data, &rule); err != nil {
+        return nil
+    }
+    return &rule
+}
+
+// spawnNextOccurrence создаёт следующий экземпляр выполненной повторяющейся задачи
+// с тем же правилом и тегами. Экземпляр, у которого уже есть продолжение, повторно
+// не порождает новую задачу, так что снятие и повторная отметка безопасны.
+// Строка задачи блокируется до конца транзакции, поэтому одновременные отметки
+// не создают два продолжения.
+func spawnNextOccurrence(taskID, userID int) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    var recurrence []byte
+    var dueDate, dueAt sql.NullTime
+    var nextTaskID sql.NullInt64
+    err = tx.QueryRow(
+        `SELECT recurrence, due_date, due_at, next_task_id FROM tasks
+         WHERE id = $1 AND user_id = $2 AND status = 'done' AND deleted_at IS NULL
+         FOR UPDATE`,
+        taskID, userID,
+    ).Scan(&recurrence, &dueDate, &dueAt, &nextTaskID)
+    if err == sql.ErrNoRows {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    rule := recurrenceFromJSON(recurrence)
+    if rule == nil || nextTaskID.Valid {
+        return nil
+    }
+
+    loc := userLocation(userID)
+    today := dateOnly(time.Now(), loc)
+    due := today
+    if dueDate.Valid {
+        y, m, d := dueDate.Time.Date()
+        due = time.Date(y, m, d, 0, 0, 0, 0, loc)
+    }
+
+    next, ok := rule.next(due, today)
+    if !ok {
+        return nil
+    }
+
+    // Время дедлайна переносится на новый день без изменений.
+    var nextAt *time.Time
+    if dueAt.Valid {
+        local := dueAt.Time.In(loc)
+        at := time.Date(next.Year(), next.Month(), next.Day(), local.Hour(), local.Minute(), 0, 0, loc)
+        nextAt = &at
+    }
+
+    var newID int
+    err = tx.QueryRow(
+        `INSERT INTO tasks (user_id, title, description, priority, due_date, due_at, subject_id, parent_id, position, recurrence)
+         SELECT user_id, title, description, priority, $2::date, $3::timestamptz, subject_id, parent_id,
+                (SELECT COALESCE(MAX(position) + 1, 0) FROM tasks WHERE parent_id = t.parent_id),
+                recurrence
+         FROM tasks t WHERE id = $1
+         RETURNING id`,
+        taskID, next.Format("2006-01-02"), nextAt,
+    ).Scan(&newID)
+    if err != nil {
+        return err
+    }
+
+    if _, err := tx.Exec(
+        "INSERT INTO task_tags (task_id, tag_id) SELECT $1, tag_id FROM task_tags WHERE task_id = $2",
+        newID, taskID,
+    ); err != nil {
+        return err
+    }
+
+    result, err := tx.Exec("UPDATE tasks SET next_task_id = $1 WHERE id = $2 AND next_task_id IS NULL", newID, taskID)
+    if err != nil {
+        return err
+    }
+    if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+        // Продолжение уже создано другим запросом: новая задача откатывается.
+        return nil
+    }
+
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    recordHistory("task", newID, userID, "create", nil)
+    return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func testDate(s string) time.Time {
+    t, _ := time.Parse("2006-01-02", s)
+    return t
+}
+
+func TestTaskRecurrenceNext(t *testing.T) {
+    // 2024-10-04 — пятница, 2024-10-06 — воскресенье.
+    cases := []struct {
+        name      string
+        rule      TaskRecurrence
+        due       string
+        completed string
+        want      string
+        wantOK    bool
+    }{
+        {"interval in days", TaskRecurrence{Mode: "interval", Interval: 3, Unit: "day"}, "2024-10-04", "2024-10-10", "2024-10-07", true},
+        {"interval in weeks", TaskRecurrence{Mode: "interval", Interval: 2, Unit: "week"}, "2024-10-04", "2024-10-10", "2024-10-18", true},
+        {"interval across month end", TaskRecurrence{Mode: "interval", Interval: 1, Unit: "week"}, "2024-10-28", "2024-10-28", "2024-11-04", true},
+        {"after_completion counts from completion", TaskRecurrence{Mode: "after_completion", Interval: 2, Unit: "day"}, "2024-10-04", "2024-10-10", "2024-10-12", true},
+        {"after_completion ahead of due", TaskRecurrence{Mode: "after_completion", Interval: 1, Unit: "week"}, "2024-10-10", "2024-10-01", "2024-10-08", true},
+        {"weekdays later this week", TaskRecurrence{Mode: "weekdays", Weekdays: []int{1, 5}}, "2024-10-01", "2024-10-01", "2024-10-04", true},
+        {"weekdays wrap to next week", TaskRecurrence{Mode: "weekdays", Weekdays: []int{1, 3}}, "2024-10-04", "2024-10-04", "2024-10-07", true},
+        {"weekdays wrap from sunday", TaskRecurrence{Mode: "weekdays", Weekdays: []int{7}}, "2024-10-06", "2024-10-06", "2024-10-13", true},
+        {"single weekday is a week later", TaskRecurrence{Mode: "weekdays", Weekdays: []int{5}}, "2024-10-04", "2024-10-04", "2024-10-11", true},
+        {"weekdays ignore completion day", TaskRecurrence{Mode: "weekdays", Weekdays: []int{1}}, "2024-10-04", "2024-10-20", "2024-10-07", true},
+        {"until on the next date", TaskRecurrence{Mode: "interval", Interval: 1, Unit: "week", Until: "2024-10-11"}, "2024-10-04", "2024-10-04", "2024-10-11", true},
+        {"until before the next date", TaskRecurrence{Mode: "interval", Interval: 1, Unit: "week", Until: "2024-10-10"}, "2024-10-04", "2024-10-04", "", false},
+        {"until stops weekdays", TaskRecurrence{Mode: "weekdays", Weekdays: []int{1}, Until: "2024-10-06"}, "2024-10-04", "2024-10-04", "", false},
+        {"until stops after_completion", TaskRecurrence{Mode: "after_completion", Interval: 1, Unit: "day", Until: "2024-10-15"}, "2024-10-04", "2024-10-15", "", false},
+        {"unknown mode", TaskRecurrence{Mode: "monthly"}, "2024-10-04", "2024-10-04", "", false},
+    }
+    for _, c := range cases {
+        got, ok := c.rule.next(testDate(c.due), testDate(c.completed))
+        if ok != c.wantOK {
+            t.Errorf("%s: next ok = %v, want %v", c.name, ok, c.wantOK)
+            continue
+        }
+        if ok && !got.Equal(testDate(c.want)) {
+            t.Errorf("%s: next = %s, want %s", c.name, got.Format("2006-01-02"), c.want)
+        }
+    }
+}
+
+func TestTaskRecurrenceNextKeepsLocalMidnight(t *testing.T) {
+    loc, err := time.LoadLocation("America/New_York")
+    if err != nil {
+        t.Skip("нет базы часовых поясов")
+    }
+    rule := TaskRecurrence{Mode: "interval", Interval: 1, Unit: "week"}
+    due := time.Date(2024, 11, 1, 0, 0, 0, 0, loc)
+
+    // Неделя через переход на зимнее время длиннее на час, но срок остаётся полуночью.
+    got, ok := rule.next(due, due)
+    if want := time.Date(2024, 11, 8, 0, 0, 0, 0, loc); !ok || !got.Equal(want) {
+        t.Errorf("next = %v, %v, want %v", got, ok, want)
+    }
+}
+
+func TestTaskRecurrenceValidate(t *testing.T) {
+    cases := []struct {
+        name string
+        rule TaskRecurrence
+        want string
+        norm TaskRecurrence
+    }{
+        {"interval defaults", TaskRecurrence{Mode: "interval"}, "", TaskRecurrence{Mode: "interval", Interval: 1, Unit: "day"}},
+        {"after_completion drops weekdays", TaskRecurrence{Mode: "after_completion", Interval: 2, Unit: "week", Weekdays: []int{1}},
+            "", TaskRecurrence{Mode: "after_completion", Interval: 2, Unit: "week"}},
+        {"weekdays drop interval", TaskRecurrence{Mode: "weekdays", Interval: 3, Unit: "day", Weekdays: []int{1, 7}},
+            "", TaskRecurrence{Mode: "weekdays", Weekdays: []int{1, 7}}},
+        {"negative interval", TaskRecurrence{Mode: "interval", Interval: -1}, "Интервал повторения должен быть положительным", TaskRecurrence{}},
+        {"bad unit", TaskRecurrence{Mode: "interval", Unit: "month"}, "Единица интервала должна быть day или week", TaskRecurrence{}},
+        {"no weekdays", TaskRecurrence{Mode: "weekdays"}, "Укажите дни недели для повторения", TaskRecurrence{}},
+        {"weekday out of range", TaskRecurrence{Mode: "weekdays", Weekdays: []int{0}}, "Дни недели задаются числами от 1 до 7", TaskRecurrence{}},
+        {"bad until", TaskRecurrence{Mode: "interval", Until: "10.10.2024"}, "Неверная дата окончания повторений", TaskRecurrence{}},
+        {"unknown mode", TaskRecurrence{Mode: "monthly"}, "Режим повторения должен быть interval, weekdays или after_completion", TaskRecurrence{}},
+    }
+    for _, c := range cases {
+        rule := c.rule
+        if got := rule.validate(); got != c.want {
+            t.Errorf("%s: validate = %q, want %q", c.name, got, c.want)
+            continue
+        }
+        if c.want != "" {
+            continue
+        }
+        if rule.Mode != c.norm.Mode || rule.Interval != c.norm.Interval || rule.Unit != c.norm.Unit ||
+            len(rule.Weekdays) != len(c.norm.Weekdays) {
+            t.Errorf("%s: validate normalized to %+v, want %+v", c.name, rule, c.norm)
+        }
+    }
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "time"
+)
+
+const maxAgendaDays = 366
+
+type CalendarEvent struct {
+    ID            int       `json:"id"`
+    Title         string    `json:"title"`
+    EventType     string    `json:"event_type"`
+    Subject       string    `json:"subject"`
+    Location      string    `json:"location"`
+    Teacher       string    `json:"teacher"`
+    Room          string    `json:"room"`
+    StartsAt      time.Time `json:"starts_at"`
+    EventDate     string    `json:"event_date"`
+    StartTime     string    `json:"start_time"`
+    EndTime       string    `json:"end_time"`
+    DurationHours float64   `json:"duration_hours"`
+}
+
+type CalendarTask struct {
+    ID          int    `json:"id"`
+    Title       string `json:"title"`
+    Priority    string `json:"priority"`
+    IsCompleted bool   `json:"is_completed"`
+    DueDate     string `json:"due_date"`
+    DueTime     string `json:"due_time"`
+}
+
+type CalendarDay struct {
+    Date    string          `json:"date"`
+    Weekday int             `json:"weekday"`
+    Events  []CalendarEvent `json:"events"`
+    Tasks   []CalendarTask  `json:"tasks"`
+}
+
+type CalendarView struct {
+    View     string        `json:"view"`
+    From     string        `json:"from"`
+    To       string        `json:"to"`
+    TimeZone string        `json:"time_zone"`
+    Days     []CalendarDay `json:"days"`
+}
+
+// isoWeekday возвращает номер дня недели, где понедельник = 1, воскресенье = 7.
+func isoWeekday(t time.Time) int {
+    weekday := int(t.Weekday())
+    if weekday == 0 {
+        weekday = 7
+    }
+    return weekday
+}
+
+func dateOnly(t time.Time, loc *time.Location) time.Time {
+    y, m, d := t.In(loc).Date()
+    return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// buildCalendar собирает события и дедлайны задач за период [from, to] по дням.
+// При skipEmpty дни без событий и задач в результат не попадают.
+func buildCalendar(userID int, from, to time.Time, loc *time.Location, skipEmpty bool) ([]CalendarDay, error) {
+    days := []CalendarDay{}
+    index := make(map[string]int)
+    for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+        key := d.Format("2006-01-02")
+        index[key] = len(days)
+        days = append(days, CalendarDay{
+            Date:    key,
+            Weekday: isoWeekday(d),
+            Events:  []CalendarEvent{},
+            Tasks:   []CalendarTask{},
+        })
+    }
+
+    fromStr := from.Format("2006-01-02")
+    toStr := to.Format("2006-01-02")
+    rangeStart, rangeEnd := localDayBounds(from, to, loc)
+
+    rows, err := db.Query(
+        `SELECT id, title, event_type, `+subjectNameColumn+`, COALESCE(location, ''),
+                `+teacherNameColumn+`, `+roomLabelColumn+`, starts_at, duration_hours
+         FROM events
+         WHERE `+visibleEventsScope("$1")+` AND deleted_at IS NULL AND starts_at >= $2 AND starts_at < $3
+         ORDER BY starts_at`,
+        userID, rangeStart, rangeEnd,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var event CalendarEvent
+        var startsAt time.Time
+        err := rows.Scan(
+            &event.ID, &event.Title, &event.EventType, &event.Subject, &event.Location,
+            &event.Teacher, &event.Room, &startsAt, &event.DurationHours,
+        )
+        if err != nil {
+            continue
+        }
+
+        startsAt = startsAt.In(loc)
+        end := startsAt.Add(time.Duration(event.DurationHours * float64(time.Hour)))
+        event.StartsAt = startsAt
+        event.EventDate = startsAt.Format("2006-01-02")
+        event.StartTime = startsAt.Format("15:04")
+        event.EndTime = end.In(loc).Format("15:04")
+
+        if i, ok := index[event.EventDate]; ok {
+            days[i].Events = append(days[i].Events, event)
+        }
+    }
+
+    taskRows, err := db.Query(
+        `SELECT id, title, priority, is_completed, to_char(due_date, 'YYYY-MM-DD'), `+taskDueTimeColumn+`
+         FROM tasks
+         WHERE user_id = $1 AND deleted_at IS NULL AND due_date BETWEEN $2 AND $3
+         ORDER BY `+taskDeadlineColumn+`, priority`,
+        userID, fromStr, toStr,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer taskRows.Close()
+
+    for taskRows.Next() {
+        var task CalendarTask
+        err := taskRows.Scan(&task.ID, &task.Title, &task.Priority, &task.IsCompleted, &task.DueDate, &task.DueTime)
+        if err != nil {
+            continue
+        }
+
+        if i, ok := index[task.DueDate]; ok {
+            days[i].Tasks = append(days[i].Tasks, task)
+        }
+    }
+
+    if !skipEmpty {
+        return days, nil
+    }
+
+    filtered := []CalendarDay{}
+    for _, day := range days {
+        if len(day.Events) > 0 || len(day.Tasks) > 0 {
+            filtered = append(filtered, day)
+        }
+    }
+    return filtered, nil
+}
+
+func writeCalendar(w http.ResponseWriter, userID int, view string, from, to time.Time, loc *time.Location) {
+    days, err := buildCalendar(userID, from, to, loc, view == "agenda")
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения расписания"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(CalendarView{
+        View:     view,
+        From:     from.Format("2006-01-02"),
+        To:       to.Format("2006-01-02"),
+        TimeZone: loc.String(),
+        Days:     days,
+    })
+}
+
+func GetDaySchedule(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    loc, err := requestLocation(r, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Неизвестный часовой пояс"}`, http.StatusBadRequest)
+        return
+    }
+
+    day := dateOnly(time.Now(), loc)
+    if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+        day, err = time.ParseInLocation("2006-01-02", dateStr, loc)
+        if err != nil {
+            http.Error(w, `{"error": "Неверный формат даты, ожидается YYYY-MM-DD"}`, http.StatusBadRequest)
+            return
+        }
+    }
+
+    writeCalendar(w, userID, "day", day, day, loc)
+}
+
+func GetMonthSchedule(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    loc, err := requestLocation(r, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Неизвестный часовой пояс"}`, http.StatusBadRequest)
+        return
+    }
+
+    today := dateOnly(time.Now(), loc)
+    start := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+    if monthStr := r.URL.Query().Get("month"); monthStr != "" {
+        start, err = time.ParseInLocation("2006-01", monthStr, loc)
+        if err != nil {
+            http.Error(w, `{"error": "Неверный формат месяца, ожидается YYYY-MM"}`, http.StatusBadRequest)
+            return
+        }
+    }
+    end := start.AddDate(0, 1, -1)
+
+    writeCalendar(w, userID, "month", start, end, loc)
+}
+
+func GetAgendaSchedule(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    loc, err := requestLocation(r, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Неизвестный часовой пояс"}`, http.StatusBadRequest)
+        return
+    }
+
+    query := r.URL.Query()
+    from, err := time.ParseInLocation("2006-01-02", query.Get("from"), loc)
+    if err != nil {
+        http.Error(w, `{"error": "Параметр from обязателен в формате YYYY-MM-DD"}`, http.StatusBadRequest)
+        return
+    }
+
+    to, err := time.ParseInLocation("2006-01-02", query.Get("to"), loc)
+    if err != nil {
+        http.Error(w, `{"error": "Параметр to обязателен в формате YYYY-MM-DD"}`, http.StatusBadRequest)
+        return
+    }
+
+    if to.Before(from) {
+        http.Error(w, `{"error": "Дата to раньше даты from"}`, http.StatusBadRequest)
+        return
+    }
+
+    if to.After(from.AddDate(0, 0, maxAgendaDays)) {
+        http.Error(w, `{"error": "Слишком большой период, максимум 366 дней"}`, http.StatusBadRequest)
+        return
+    }
+
+    writeCalendar(w, userID, "agenda", from, to, loc)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// sessionTTL — сколько действует токен сессии, выданный при входе.
+const sessionTTL = 30 * 24 * time.Hour
+
+type contextKey string
+
+// sessionUserKey — ключ контекста запроса с id пользователя, чью сессию проверил authenticate.
+const sessionUserKey contextKey = "session_user"
+
+// newToken генерирует случайный токен для сессии или второго шага входа.
+func newToken() (string, error) {
+    buf := make([]byte, 32)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken — то, что хранится в базе вместо токена: утечка таблицы не даёт войти.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// bearerToken достаёт токен из заголовка Authorization: Bearer <токен>.
+func bearerToken(r *http.Request) string {
+    header := r.Header.Get("Authorization")
+    if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+        return ""
+    }
+    return strings.TrimSpace(header[7:])
+}
+
+// createSession открывает сессию пользователя; заодно удаляются его истёкшие сессии.
+func createSession(userID int) (string, time.Time, error) {
+    token, err := newToken()
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    expiresAt := time.Now().Add(sessionTTL)
+
+    db.Exec("DELETE FROM sessions WHERE user_id = $1 AND expires_at < CURRENT_TIMESTAMP", userID)
+    _, err = db.Exec(
+        "INSERT INTO sessions (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+        hashToken(token), userID, expiresAt,
+    )
+    return token, expiresAt, err
+}
+
+// writeSession открывает сессию и отвечает данными пользователя вместе с токеном,
+// который клиент дальше присылает в заголовке Authorization.
+func writeSession(w http.ResponseWriter, status int, user User) {
+    token, expiresAt, err := createSession(user.ID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка входа"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(struct {
+        User
+        Token     string    `json:"token"`
+        ExpiresAt time.Time `json:"expires_at"`
+    }{user, token, expiresAt})
+}
+
+// authenticate определяет пользователя по токену сессии и кладёт его id в контекст
+// запроса. Без действующей сессии запрос идёт дальше анонимным, и обработчики данных
+// отвечают 401; заблокированной учётной записи сразу отвечает 403.
+func authenticate(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        token := bearerToken(r)
+        if token == "" {
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        var userID int
+        var locked bool
+        err := db.QueryRow(
+            `SELECT s.user_id, u.locked_at IS NOT NULL
+             FROM sessions s JOIN users u ON u.id = s.user_id
+             WHERE s.token_hash = $1 AND s.expires_at > CURRENT_TIMESTAMP`,
+            hashToken(token),
+        ).Scan(&userID, &locked)
+        if err == sql.ErrNoRows {
+            next.ServeHTTP(w, r)
+            return
+        } else if err != nil {
+            http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+            return
+        }
+
+        if locked {
+            http.Error(w, `{"error": "Учётная запись заблокирована"}`, http.StatusForbidden)
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionUserKey, userID)))
+    })
+}
+
+// deleteUserSessions завершает все сессии пользователя, например после сброса пароля.
+func deleteUserSessions(userID int) error {
+    _, err := db.Exec("DELETE FROM sessions WHERE user_id = $1", userID)
+    return err
+}
+
+// Logout завершает сессию, с которой пришёл запрос.
+func Logout(w http.ResponseWriter, r *http.Request) {
+    if token := bearerToken(r); token != "" {
+        if _, err := db.Exec("DELETE FROM sessions WHERE token_hash = $1", hashToken(token)); err != nil {
+            http.Error(w, `{"error": "Ошибка выхода"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Сессия завершена"})
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+type ItemShare struct {
+    UserID     int       `json:"user_id"`
+    Name       string    `json:"name"`
+    Email      string    `json:"email"`
+    Permission string    `json:"permission"`
+    CreatedAt  time.Time `json:"created_at"`
+}
+
+// SharedItem — событие или задача из входящих: чем поделились с текущим пользователем.
+type SharedItem struct {
+    Type       string    `json:"type"`
+    Permission string    `json:"permission"`
+    OwnerID    int       `json:"owner_id"`
+    Owner      string    `json:"owner"`
+    SharedAt   time.Time `json:"shared_at"`
+    Event      *Event    `json:"event,omitempty"`
+    Task       *Task     `json:"task,omitempty"`
+}
+
+// sharedScope — условие на строки events или tasks, которыми поделились с пользователем
+// из параметра param. При editOnly учитываются только доступы с правом edit.
+func sharedScope(entityType, param string, editOnly bool) string {
+    scope := `id IN (SELECT entity_id FROM item_shares WHERE entity_type = '` + entityType + `' AND user_id = ` + param
+    if editOnly {
+        scope += ` AND permission = 'edit'`
+    }
+    return scope + `)`
+}
+
+// editableTasksScope — условие на задачи, которые пользователь из param может менять:
+// свои и полученные с правом edit.
+func editableTasksScope(param string) string {
+    return `(user_id = ` + param + ` OR ` + sharedScope("task", param, true) + `)`
+}
+
+// taskOwner возвращает владельца задачи, если пользователь владеет ею или получил
+// к ней доступ: permission "edit" требует права на изменение, "read" — любого доступа.
+// Запросы по дереву задачи дальше выполняются от имени владельца.
+func taskOwner(q sqlQueryer, taskID, userID int, permission string) (int, error) {
+    scope := editableTasksScope("$2")
+    if permission == "read" {
+        scope = `(user_id = $2 OR ` + sharedScope("task", "$2", false) + `)`
+    }
+
+    var ownerID int
+    err := q.QueryRow(
+        "SELECT user_id FROM tasks WHERE id = $1 AND deleted_at IS NULL AND "+scope,
+        taskID, userID,
+    ).Scan(&ownerID)
+    return ownerID, err
+}
+
+// shareEntity разбирает {type} из маршрута /api/{type}/{id}/shares.
+func shareEntity(r *http.Request) (string, int) {
+    vars := mux.Vars(r)
+    id, _ := strconv.Atoi(vars["id"])
+    if strings.HasPrefix(r.URL.Path, "/api/tasks/") {
+        return "task", id
+    }
+    return "event", id
+}
+
+// ownsEntity сообщает, владеет ли пользователь объектом: выдавать и отзывать доступ
+// может только владелец, даже если сам объект доступен на изменение через share.
+// Объектами из корзины делиться нельзя.
+func ownsEntity(entityType string, id, userID int) bool {
+    var exists bool
+    db.QueryRow(
+        "SELECT EXISTS (SELECT 1 FROM "+historyTables[entityType]+" WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)",
+        id, userID,
+    ).Scan(&exists)
+    return exists
+}
+
+func GetItemShares(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    entityType, id := shareEntity(r)
+    if !ownsEntity(entityType, id, userID) {
+        http.Error(w, `{"error": "Объект не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    rows, err := db.Query(
+        `SELECT u.id, u.name, u.email, s.permission, s.created_at
+         FROM item_shares s JOIN users u ON u.id = s.user_id
+         WHERE s.entity_type = $1 AND s.entity_id = $2
+         ORDER BY u.name`,
+        entityType, id,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения доступов"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    shares := []ItemShare{}
+    for rows.Next() {
+        var share ItemShare
+        if err := rows.Scan(&share.UserID, &share.Name, &share.Email, &share.Permission, &share.CreatedAt); err != nil {
+            continue
+        }
+        shares = append(shares, share)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(shares)
+}
+
+// ShareItem выдаёт пользователю с указанным email доступ read или edit.
+// Повторный вызов меняет право уже выданного доступа. Ответ не зависит от того,
+// зарегистрирован ли такой email, чтобы по нему нельзя было перебирать пользователей.
+func ShareItem(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    entityType, id := shareEntity(r)
+
+    var req struct {
+        Email      string `json:"email"`
+        Permission string `json:"permission"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if req.Permission == "" {
+        req.Permission = "read"
+    }
+    if req.Permission != "read" && req.Permission != "edit" {
+        writeBadRequest(w, "Доступ должен быть read или edit")
+        return
+    }
+
+    if !ownsEntity(entityType, id, userID) {
+        http.Error(w, `{"error": "Объект не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    req.Email = strings.TrimSpace(req.Email)
+    if req.Email == "" {
+        writeBadRequest(w, "Введите email пользователя")
+        return
+    }
+
+    var granteeID int
+    err := db.QueryRow("SELECT id FROM users WHERE LOWER(email) = LOWER($1)", req.Email).Scan(&granteeID)
+    if err != nil && err != sql.ErrNoRows {
+        http.Error(w, `{"error": "Ошибка выдачи доступа"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if err == nil {
+        // Свой email пользователь и так знает, так что этот отказ ничего не раскрывает.
+        if granteeID == userID {
+            writeBadRequest(w, "Нельзя поделиться с самим собой")
+            return
+        }
+
+        _, err = db.Exec(
+            `INSERT INTO item_shares (entity_type, entity_id, owner_id, user_id, permission)
+             VALUES ($1, $2, $3, $4, $5)
+             ON CONFLICT (entity_type, entity_id, user_id) DO UPDATE SET permission = EXCLUDED.permission`,
+            entityType, id, userID, granteeID, req.Permission,
+        )
+        if err != nil {
+            http.Error(w, `{"error": "Ошибка выдачи доступа"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(map[string]string{
+        "message":    "Если пользователь с таким email зарегистрирован, он получил доступ",
+        "email":      req.Email,
+        "permission": req.Permission,
+    })
+}
+
+// RevokeItemShare отзывает доступ; получатель может и сам отказаться от него.
+func RevokeItemShare(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    entityType, id := shareEntity(r)
+    granteeID, _ := strconv.Atoi(mux.Vars(r)["userId"])
+
+    result, err := db.Exec(
+        `DELETE FROM item_shares
+         WHERE entity_type = $1 AND entity_id = $2 AND user_id = $3 AND (owner_id = $4 OR user_id = $4)`,
+        entityType, id, granteeID, userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка отзыва доступа"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Доступ не найден"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Доступ отозван"})
+}
+
+// GetSharedWithMe — входящие: события и задачи других пользователей, к которым выдан доступ.
+// Объекты из корзины владельца не показываются.
+func GetSharedWithMe(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    rows, err := db.Query(
+        `SELECT s.entity_type, s.entity_id, s.permission, s.owner_id, COALESCE(u.name, ''), s.created_at
+         FROM item_shares s LEFT JOIN users u ON u.id = s.owner_id
+         WHERE s.user_id = $1
+         ORDER BY s.created_at DESC`,
+        userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения входящих"}`, http.StatusInternalServerError)
+        return
+    }
+
+    type shareRow struct {
+        item SharedItem
+        id   int
+    }
+    shares := []shareRow{}
+    for rows.Next() {
+        var share shareRow
+        err := rows.Scan(
+            &share.item.Type, &share.id, &share.item.Permission, &share.item.OwnerID,
+            &share.item.Owner, &share.item.SharedAt,
+        )
+        if err != nil {
+            continue
+        }
+        shares = append(shares, share)
+    }
+    rows.Close()
+
+    loc := userLocation(userID)
+    items := []SharedItem{}
+    for _, share := range shares {
+        item := share.item
+        if item.Type == "event" {
+            event, err := scanEvent(db.QueryRow(
+                `SELECT `+eventColumns("$2")+` FROM events WHERE id = $1 AND deleted_at IS NULL`,
+                share.id, userID,
+            ), loc)
+            if err != nil {
+                continue
+            }
+            item.Event = &event
+        } else {
+            task, err := loadTaskTree(share.id, item.OwnerID)
+            if err != nil {
+                continue
+            }
+            item.Task = &task
+        }
+        items = append(items, item)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "net/http"
+    "strings"
+)
+
+// saveError — отказ в записи события или задачи с HTTP-статусом для клиента.
+// Одиночные запросы отвечают им напрямую, пакетные — кладут в результат операции.
+type saveError struct {
+    status  int
+    message string
+}
+
+func (e *saveError) Error() string {
+    return e.message
+}
+
+func saveFail(status int, message string) error {
+    return &saveError{status: status, message: message}
+}
+
+// versionConflictError — строку изменили после проверки If-Match.
+type versionConflictError struct {
+    entityType string
+    id         int
+}
+
+func (e *versionConflictError) Error() string {
+    return "Объект был изменён в другом месте, обновите данные"
+}
+
+// roomConflictError — аудитория занята пересекающимися событиями.
+type roomConflictError struct {
+    conflicts []RoomConflict
+}
+
+func (e *roomConflictError) Error() string {
+    return "Аудитория уже занята в это время"
+}
+
+// writeSaveError отвечает на отказ storeEvent или storeTask; прочие ошибки
+// становятся ответом 500 с текстом fallback.
+func writeSaveError(w http.ResponseWriter, err error, fallback string) {
+    switch failure := err.(type) {
+    case *saveError:
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(failure.status)
+        json.NewEncoder(w).Encode(map[string]string{"error": failure.message})
+    case *roomConflictError:
+        writeRoomConflicts(w, failure.conflicts)
+    case *transitionError:
+        writeTransitionError(w, failure)
+    case *versionConflictError:
+        writeVersionConflict(w, currentVersion(failure.entityType, failure.id))
+    default:
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusInternalServerError)
+        json.NewEncoder(w).Encode(map[string]string{"error": fallback})
+    }
+}
+
+// validateEventInput проверяет обязательные поля события, передаваемого целиком.
+func validateEventInput(req eventInput) string {
+    if strings.TrimSpace(req.Title) == "" {
+        return "Введите название события"
+    }
+    if strings.TrimSpace(req.EventType) == "" {
+        return "Укажите тип события"
+    }
+    if req.DurationHours <= 0 {
+        return "Продолжительность должна быть положительной"
+    }
+    return ""
+}
+
+// validateTaskInput проверяет обязательные поля задачи, передаваемой целиком.
+func validateTaskInput(req taskInput) string {
+    if strings.TrimSpace(req.Title) == "" {
+        return "Введите название задачи"
+    }
+    if !taskPriorities[req.Priority] {
+        return "Приоритет должен быть low, medium или high"
+    }
+    return ""
+}
+
+// storeEvent создаёт событие при eventID = 0, иначе полностью перезаписывает его,
+// если версия строки всё ещё равна version (0 — без условия, см. checkIfMatch).
+// Все проверки и записи идут через q: в пакете это транзакция, и занятость аудитории
+// учитывает события, созданные предыдущими операциями.
+func storeEvent(q sqlQueryer, userID, eventID, version int, req eventInput) (int, error) {
+    startsAt, err := parseEventStart(req.StartsAt, req.EventDate, req.StartTime, userLocation(userID))
+    if err != nil {
+        return 0, saveFail(http.StatusBadRequest, "Неверная дата или время начала события")
+    }
+
+    // Справочники и теги общего события принадлежат его автору,
+    // даже если событие правит другой редактор группы.
+    ownerID := userID
+    if eventID != 0 {
+        ownerID, err = eventOwner(q, eventID, userID)
+        if err == sql.ErrNoRows {
+            return 0, saveFail(http.StatusNotFound, "Событие не найдено или нет прав доступа")
+        } else if err != nil {
+            return 0, err
+        }
+    } else if req.GroupID != nil {
+        // Общее событие группы могут добавлять её владелец и редакторы.
+        role, err := groupRole(*req.GroupID, userID)
+        if err != nil {
+            return 0, err
+        }
+        if role == "" {
+            return 0, saveFail(http.StatusNotFound, "Группа не найдена или нет прав доступа")
+        }
+        if groupRoleRank[role] < groupRoleRank["editor"] {
+            return 0, saveFail(http.StatusForbidden, "Недостаточно прав в группе")
+        }
+    }
+
+    links, err := resolveEventLinks(q, ownerID, req)
+    if err == errSubjectNotFound || err == errDirectoryNotFound {
+        return 0, saveFail(http.StatusBadRequest, "Предмет, преподаватель или аудитория не найдены")
+    } else if err != nil {
+        return 0, err
+    }
+
+    if links.RoomID != nil {
+        conflicts, err := findRoomConflicts(q, ownerID, *links.RoomID, eventID, startsAt, req.DurationHours)
+        if err != nil {
+            return 0, err
+        }
+        if len(conflicts) > 0 {
+            return 0, &roomConflictError{conflicts: conflicts}
+        }
+    }
+
+    if eventID == 0 {
+        err = q.QueryRow(
+            `INSERT INTO events (user_id, title, description, event_type, subject_id,
+                                location, teacher_id, room_id, starts_at, duration_hours, group_id)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+             RETURNING id`,
+            userID, req.Title, req.Description, req.EventType, links.SubjectID,
+            req.Location, links.TeacherID, links.RoomID, startsAt, req.DurationHours, req.GroupID,
+        ).Scan(&eventID)
+        if err != nil {
+            return 0, err
+        }
+    } else {
+        result, err := q.Exec(
+            `UPDATE events
+             SET title = $1, description = $2, event_type = $3, subject_id = $4,
+                 location = $5, teacher_id = $6, room_id = $7, starts_at = $8, duration_hours = $9
+             WHERE id = $10 AND user_id = $11 AND deleted_at IS NULL AND `+versionCondition("$12"),
+            req.Title, req.Description, req.EventType, links.SubjectID,
+            req.Location, links.TeacherID, links.RoomID, startsAt, req.DurationHours,
+            eventID, ownerID, version,
+        )
+        if err != nil {
+            return 0, err
+        }
+        if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+            if version != 0 {
+                return 0, &versionConflictError{entityType: "event", id: eventID}
+            }
+            return 0, saveFail(http.StatusNotFound, "Событие не найдено или нет прав доступа")
+        }
+    }
+
+    if err := replaceTagsWith(q, "event_tags", "event_id", eventID, ownerID, req.TagIDs); err != nil {
+        return 0, err
+    }
+    return eventID, nil
+}
+
+// storeTask создаёт задачу под родителем parentID при taskID = 0, иначе полностью
+// перезаписывает её; родитель задаётся только при создании. Отметка о выполнении
+// проходит те же проверки переходов и зависимостей, что и смена статуса.
+// Возвращает id задачи и её владельца; запись и условие на version — как в storeEvent.
+func storeTask(q sqlQueryer, userID, taskID, version int, parentID *int, req taskInput) (int, int, error) {
+    if req.Recurrence != nil {
+        if msg := req.Recurrence.validate(); msg != "" {
+            return 0, 0, saveFail(http.StatusBadRequest, msg)
+        }
+    }
+
+    dueDate, dueAt, err := parseTaskDue(req.DueAt, req.DueDate, req.DueTime, userLocation(userID))
+    if err != nil {
+        return 0, 0, saveFail(http.StatusBadRequest, "Неверный срок выполнения задачи")
+    }
+
+    // Предмет и теги задачи, к которой выдан доступ, остаются предметом и тегами владельца.
+    ownerID := userID
+    if taskID != 0 {
+        ownerID, err = taskOwner(q, taskID, userID, "edit")
+        if err == sql.ErrNoRows {
+            return 0, 0, saveFail(http.StatusNotFound, "Задача не найдена или нет прав доступа")
+        } else if err != nil {
+            return 0, 0, err
+        }
+    }
+
+    subjectID, err := resolveSubject(q, ownerID, req.SubjectID, req.Subject)
+    if err == errSubjectNotFound {
+        return 0, 0, saveFail(http.StatusBadRequest, "Предмет не найден")
+    } else if err != nil {
+        return 0, 0, err
+    }
+
+    if taskID == 0 {
+        parent, parentSubjectID, err := resolveParentTask(q, userID, parentID)
+        if err == errParentNotFound {
+            return 0, 0, saveFail(http.StatusBadRequest, "Родительская задача не найдена")
+        } else if err != nil {
+            return 0, 0, err
+        }
+        if subjectID == nil {
+            subjectID = parentSubjectID
+        }
+
+        err = q.QueryRow(
+            `INSERT INTO tasks (user_id, title, description, priority, due_date, due_at, subject_id, parent_id, position, recurrence)
+             VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, $9, $6, $7,
+                     (SELECT COALESCE(MAX(position) + 1, 0) FROM tasks WHERE parent_id = $7), $8)
+             RETURNING id`,
+            userID, req.Title, req.Description, req.Priority, dueDate, subjectID, parent,
+            recurrenceValue(req.Recurrence), dueAt,
+        ).Scan(&taskID)
+        if err != nil {
+            return 0, 0, err
+        }
+
+        if req.IsCompleted {
+            if _, err := q.Exec(
+                `UPDATE tasks SET `+taskStatusAssignments("'done'")+` WHERE id = $1`,
+                taskID,
+            ); err != nil {
+                return 0, 0, err
+            }
+        }
+    } else {
+        current, err := taskStatus(q, taskID, ownerID)
+        if err == sql.ErrNoRows {
+            return 0, 0, saveFail(http.StatusNotFound, "Задача не найдена или нет прав доступа")
+        } else if err != nil {
+            return 0, 0, err
+        }
+        status := completionStatus(current, req.IsCompleted)
+        if err := checkTaskTransition(q, taskID, ownerID, current, status, "SELECT $1::int", false); err != nil {
+            return 0, 0, err
+        }
+
+        result, err := q.Exec(
+            `UPDATE tasks
+             SET title = $1, description = $2, priority = $3,
+                 due_date = NULLIF($5, '')::date, due_at = $10, subject_id = $6, recurrence = $9,
+                 `+taskStatusAssignments("$4::varchar")+`
+             WHERE id = $7 AND user_id = $8 AND deleted_at IS NULL AND `+versionCondition("$11"),
+            req.Title, req.Description, req.Priority, status, dueDate, subjectID,
+            taskID, ownerID, recurrenceValue(req.Recurrence), dueAt, version,
+        )
+        if err != nil {
+            return 0, 0, err
+        }
+        if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+            if version != 0 {
+                return 0, 0, &versionConflictError{entityType: "task", id: taskID}
+            }
+            return 0, 0, saveFail(http.StatusNotFound, "Задача не найдена или нет прав доступа")
+        }
+    }
+
+    if err := replaceTagsWith(q, "task_tags", "task_id", taskID, ownerID, req.TagIDs); err != nil {
+        return 0, 0, err
+    }
+    return taskID, ownerID, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+var errSubjectNotFound = errors.New("предмет не найден")
+
+type Subject struct {
+    ID            int       `json:"id"`
+    UserID        int       `json:"user_id"`
+    Name          string    `json:"name"`
+    Teacher       string    `json:"teacher"`
+    Color         string    `json:"color"`
+    Credits       float64   `json:"credits"`
+    Semester      int       `json:"semester"`
+    MinAttendance float64   `json:"min_attendance"`
+    CreatedAt     time.Time `json:"created_at"`
+}
+
+type subjectInput struct {
+    Name          string  `json:"name"`
+    Teacher       string  `json:"teacher"`
+    Color         string  `json:"color"`
+    Credits       float64 `json:"credits"`
+    Semester      int     `json:"semester"`
+    MinAttendance float64 `json:"min_attendance"`
+}
+
+const subjectColumns = `id, user_id, name, COALESCE(teacher, ''), COALESCE(color, ''),
+                credits, COALESCE(semester, 0), min_attendance, created_at`
+
+// subjectNameColumn подставляет название предмета в выборки из events и tasks.
+const subjectNameColumn = `COALESCE((SELECT name FROM subjects WHERE subjects.id = subject_id), '')`
+
+func scanSubject(row rowScanner) (Subject, error) {
+    var subject Subject
+    err := row.Scan(
+        &subject.ID, &subject.UserID, &subject.Name, &subject.Teacher,
+        &subject.Color, &subject.Credits, &subject.Semester, &subject.MinAttendance,
+        &subject.CreatedAt,
+    )
+    return subject, err
+}
+
+// resolveSubject проверяет, что предмет принадлежит пользователю. Если передано
+// только название, предмет находится по нему или создаётся.
+func resolveSubject(q sqlQueryer, userID int, subjectID *int, name string) (*int, error) {
+    if subjectID != nil && *subjectID > 0 {
+        var id int
+        err := q.QueryRow(
+            "SELECT id FROM subjects WHERE id = $1 AND user_id = $2",
+            *subjectID, userID,
+        ).Scan(&id)
+        if err == sql.ErrNoRows {
+            return nil, errSubjectNotFound
+        }
+        if err != nil {
+            return nil, err
+        }
+        return &id, nil
+    }
+
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return nil, nil
+    }
+
+    var id int
+    err := q.QueryRow(
+        `INSERT INTO subjects (user_id, name) VALUES ($1, $2)
+         ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+         RETURNING id`,
+        userID, name,
+    ).Scan(&id)
+    if err != nil {
+        return nil, err
+    }
+    return &id, nil
+}
+
+// subjectFilter разбирает параметр ?subject_id= списочных запросов.
+func subjectFilter(r *http.Request) (int, bool) {
+    subjectID, err := strconv.Atoi(r.URL.Query().Get("subject_id"))
+    if err != nil || subjectID <= 0 {
+        return 0, false
+    }
+    return subjectID, true
+}
+
+func GetSubjects(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    query := `SELECT ` + subjectColumns + ` FROM subjects WHERE user_id = $1`
+    args := []interface{}{userID}
+    if semester, err := strconv.Atoi(r.URL.Query().Get("semester")); err == nil {
+        query += ` AND semester = $2`
+        args = append(args, semester)
+    }
+    query += ` ORDER BY name`
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения предметов"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    subjects := []Subject{}
+    for rows.Next() {
+        subject, err := scanSubject(rows)
+        if err != nil {
+            continue
+        }
+        subjects = append(subjects, subject)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(subjects)
+}
+
+func CreateSubject(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req subjectInput
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        http.Error(w, `{"error": "Введите название предмета"}`, http.StatusBadRequest)
+        return
+    }
+
+    if req.MinAttendance < 0 || req.MinAttendance > 100 {
+        http.Error(w, `{"error": "Норма посещаемости должна быть от 0 до 100"}`, http.StatusBadRequest)
+        return
+    }
+
+    subject, err := scanSubject(db.QueryRow(
+        `INSERT INTO subjects (user_id, name, teacher, color, credits, semester, min_attendance)
+         VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7)
+         ON CONFLICT (user_id, name) DO NOTHING
+         RETURNING `+subjectColumns,
+        userID, req.Name, req.Teacher, req.Color, req.Credits, req.Semester, req.MinAttendance,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Предмет с таким названием уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка создания предмета"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(subject)
+}
+
+func UpdateSubject(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    subjectID, _ := strconv.Atoi(vars["id"])
+
+    var req subjectInput
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        http.Error(w, `{"error": "Введите название предмета"}`, http.StatusBadRequest)
+        return
+    }
+
+    if req.MinAttendance < 0 || req.MinAttendance > 100 {
+        http.Error(w, `{"error": "Норма посещаемости должна быть от 0 до 100"}`, http.StatusBadRequest)
+        return
+    }
+
+    subject, err := scanSubject(db.QueryRow(
+        `UPDATE subjects
+         SET name = $1, teacher = $2, color = $3, credits = $4, semester = NULLIF($5, 0),
+             min_attendance = $6
+         WHERE id = $7 AND user_id = $8
+         RETURNING `+subjectColumns,
+        req.Name, req.Teacher, req.Color, req.Credits, req.Semester, req.MinAttendance,
+        subjectID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Предмет не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if isUniqueViolation(err) {
+        http.Error(w, `{"error": "Предмет с таким названием уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления предмета"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(subject)
+}
+
+func DeleteSubject(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    subjectID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM subjects WHERE id = $1 AND user_id = $2", subjectID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления предмета"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Предмет не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Предмет удален"})
+}
+
+func GetSubjectStats(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    type SubjectStats struct {
+        SubjectID      *int    `json:"subject_id"`
+        Name           string  `json:"name"`
+        Color          string  `json:"color"`
+        TotalEvents    int     `json:"total_events"`
+        StudyHours     float64 `json:"study_hours"`
+        TotalTasks     int     `json:"total_tasks"`
+        CompletedTasks int     `json:"completed_tasks"`
+    }
+
+    // Предмет NULL собирает события и задачи без предмета.
+    rows, err := db.Query(
+        `SELECT s.id, COALESCE(s.name, ''), COALESCE(s.color, ''),
+                COALESCE(e.total, 0), COALESCE(e.hours, 0),
+                COALESCE(t.total, 0), COALESCE(t.completed, 0)
+         FROM (SELECT id, name, color FROM subjects WHERE user_id = $1
+               UNION ALL SELECT NULL, NULL, NULL) s
+         LEFT JOIN (SELECT subject_id, COUNT(*) AS total, SUM(duration_hours) AS hours
+                    FROM events WHERE user_id = $1 AND deleted_at IS NULL GROUP BY subject_id) e
+                ON e.subject_id IS NOT DISTINCT FROM s.id
+         LEFT JOIN (SELECT subject_id, COUNT(*) AS total,
+                           COUNT(*) FILTER (WHERE is_completed) AS completed
+                    FROM tasks WHERE user_id = $1 AND deleted_at IS NULL GROUP BY subject_id) t
+                ON t.subject_id IS NOT DISTINCT FROM s.id
+         WHERE s.id IS NOT NULL OR e.total IS NOT NULL OR t.total IS NOT NULL
+         ORDER BY s.name NULLS LAST`,
+        userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    stats := []SubjectStats{}
+    for rows.Next() {
+        var item SubjectStats
+        var subjectID sql.NullInt64
+        err := rows.Scan(
+            &subjectID, &item.Name, &item.Color, &item.TotalEvents, &item.StudyHours,
+            &item.TotalTasks, &item.CompletedTasks,
+        )
+        if err != nil {
+            continue
+        }
+        if subjectID.Valid {
+            id := int(subjectID.Int64)
+            item.SubjectID = &id
+        }
+        stats = append(stats, item)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "sort"
+    "strconv"
+
+    "github.com/gorilla/mux"
+)
+
+var errParentNotFound = errors.New("родительская задача не найдена")
+
+// taskSubtreeCTE выбирает задачу $1 пользователя $2 вместе со всеми вложенными подзадачами,
+// не находящимися в корзине.
+const taskSubtreeCTE = `WITH RECURSIVE subtree AS (
+        SELECT id FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+        UNION ALL
+        SELECT t.id FROM tasks t JOIN subtree ON t.parent_id = subtree.id WHERE t.deleted_at IS NULL
+    )`
+
+// buildTaskTree раскладывает список задач в дерево по parent_id и считает прогресс.
+// Задачи, чей родитель не попал в список, становятся корнями; порядок корней сохраняется.
+func buildTaskTree(tasks []Task) []Task {
+    byID := make(map[int]Task, len(tasks))
+    for _, task := range tasks {
+        byID[task.ID] = task
+    }
+
+    children := make(map[int][]int)
+    roots := []int{}
+    for _, task := range tasks {
+        if task.ParentID != nil {
+            if _, ok := byID[*task.ParentID]; ok {
+                children[*task.ParentID] = append(children[*task.ParentID], task.ID)
+                continue
+            }
+        }
+        roots = append(roots, task.ID)
+    }
+
+    var build func(id int) Task
+    build = func(id int) Task {
+        task := byID[id]
+        ids := children[id]
+        sort.SliceStable(ids, func(i, j int) bool {
+            return byID[ids[i]].Position < byID[ids[j]].Position
+        })
+
+        task.Subtasks = []Task{}
+        for _, childID := range ids {
+            task.Subtasks = append(task.Subtasks, build(childID))
+        }
+        task.Progress = taskProgress(task)
+        return task
+    }
+
+    tree := []Task{}
+    for _, id := range roots {
+        tree = append(tree, build(id))
+    }
+    return tree
+}
+
+// taskProgress — процент выполнения: для листа 0 или 100, для родителя среднее по подзадачам.
+// Отменённые подзадачи в среднее не входят.
+func taskProgress(task Task) float64 {
+    if task.IsCompleted {
+        return 100
+    }
+
+    var sum float64
+    var count int
+    for _, subtask := range task.Subtasks {
+        if subtask.Status == "cancelled" {
+            continue
+        }
+        sum += subtask.Progress
+        count++
+    }
+    if count == 0 {
+        return 0
+    }
+    return roundTo(sum/float64(count), 2)
+}
+
+// applyTaskProgress заполняет прогресс в плоском списке задач.
+func applyTaskProgress(tasks []Task) {
+    progress := make(map[int]float64)
+    var walk func(nodes []Task)
+    walk = func(nodes []Task) {
+        for _, node := range nodes {
+            progress[node.ID] = node.Progress
+            walk(node.Subtasks)
+        }
+    }
+    walk(buildTaskTree(tasks))
+
+    for i := range tasks {
+        tasks[i].Progress = progress[tasks[i].ID]
+    }
+}
+
+// filterTasksByStatus оставляет задачи со статусом status; пустой статус ничего не отсекает.
+// Фильтр применяется после buildTaskTree или applyTaskProgress, чтобы прогресс родителя
+// считался по всем подзадачам, а не только по подходящим под фильтр.
+func filterTasksByStatus(tasks []Task, status string) []Task {
+    if status == "" {
+        return tasks
+    }
+    filtered := []Task{}
+    for _, task := range tasks {
+        if task.Status == status {
+            filtered = append(filtered, task)
+        }
+    }
+    return filtered
+}
+
+// loadTaskTree возвращает задачу вместе с деревом подзадач.
+func loadTaskTree(taskID, userID int) (Task, error) {
+    rows, err := db.Query(
+        taskSubtreeCTE+`
+        SELECT `+taskColumns+` FROM tasks WHERE id IN (SELECT id FROM subtree)`,
+        taskID, userID,
+    )
+    if err != nil {
+        return Task{}, err
+    }
+    defer rows.Close()
+
+    tasks := []Task{}
+    for rows.Next() {
+        task, err := scanTask(rows)
+        if err != nil {
+            continue
+        }
+        tasks = append(tasks, task)
+    }
+
+    for _, task := range buildTaskTree(tasks) {
+        if task.ID == taskID {
+            return task, nil
+        }
+    }
+    return Task{}, sql.ErrNoRows
+}
+
+// resolveParentTask проверяет родительскую задачу и возвращает её предмет,
+// который наследует подзадача без собственного предмета.
+func resolveParentTask(q sqlQueryer, userID int, parentID *int) (*int, *int, error) {
+    if parentID == nil || *parentID <= 0 {
+        return nil, nil, nil
+    }
+
+    var id int
+    var subjectID sql.NullInt64
+    err := q.QueryRow(
+        "SELECT id, subject_id FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+        *parentID, userID,
+    ).Scan(&id, &subjectID)
+    if err == sql.ErrNoRows {
+        return nil, nil, errParentNotFound
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+    return &id, nullIntPtr(subjectID), nil
+}
+
+func ReorderSubtasks(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Order []int `json:"order"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    rows, err := db.Query(
+        "SELECT id FROM tasks WHERE parent_id = $1 AND user_id = $2 AND deleted_at IS NULL",
+        taskID, userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения подзадач"}`, http.StatusInternalServerError)
+        return
+    }
+
+    children := make(map[int]bool)
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err == nil {
+            children[id] = true
+        }
+    }
+    rows.Close()
+
+    if len(req.Order) != len(children) {
+        http.Error(w, `{"error": "Порядок должен содержать все подзадачи ровно один раз"}`, http.StatusBadRequest)
+        return
+    }
+    seen := make(map[int]bool)
+    for _, id := range req.Order {
+        if !children[id] || seen[id] {
+            http.Error(w, `{"error": "Порядок должен содержать все подзадачи ровно один раз"}`, http.StatusBadRequest)
+            return
+        }
+        seen[id] = true
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка изменения порядка"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    for position, id := range req.Order {
+        if _, err := tx.Exec("UPDATE tasks SET position = $1 WHERE id = $2", position, id); err != nil {
+            http.Error(w, `{"error": "Ошибка изменения порядка"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка изменения порядка"}`, http.StatusInternalServerError)
+        return
+    }
+
+    task, err := loadTaskTree(taskID, userID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Порядок изменен, но задача не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(task)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+)
+
+// testTask — задача для дерева: id, родитель (0 — корень), позиция и статус.
+func testTask(id, parentID, position int, status string) Task {
+    task := Task{ID: id, Position: position, Status: status, IsCompleted: status == "done"}
+    if parentID != 0 {
+        task.ParentID = &parentID
+    }
+    return task
+}
+
+// treeShape записывает дерево как id(дети...) для сравнения в тестах.
+func treeShape(tasks []Task) string {
+    shape := ""
+    for i, task := range tasks {
+        if i > 0 {
+            shape += " "
+        }
+        shape += fmt.Sprint(task.ID)
+        if len(task.Subtasks) > 0 {
+            shape += "(" + treeShape(task.Subtasks) + ")"
+        }
+    }
+    return shape
+}
+
+func findTask(tasks []Task, id int) *Task {
+    for i := range tasks {
+        if tasks[i].ID == id {
+            return &tasks[i]
+        }
+        if found := findTask(tasks[i].Subtasks, id); found != nil {
+            return found
+        }
+    }
+    return nil
+}
+
+func TestBuildTaskTree(t *testing.T) {
+    cases := []struct {
+        name  string
+        tasks []Task
+        want  string
+    }{
+        {"flat roots keep order", []Task{testTask(3, 0, 0, "todo"), testTask(1, 0, 0, "todo"), testTask(2, 0, 0, "todo")}, "3 1 2"},
+        {"children sorted by position", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 2, "todo"), testTask(3, 1, 0, "todo"), testTask(4, 1, 1, "todo"),
+        }, "1(3 4 2)"},
+        {"child listed before parent", []Task{testTask(2, 1, 0, "todo"), testTask(1, 0, 0, "todo")}, "1(2)"},
+        {"nested levels", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "todo"), testTask(3, 2, 0, "todo"), testTask(4, 3, 0, "todo"),
+        }, "1(2(3(4)))"},
+        // Родитель не попал в выборку (другой фильтр, корзина): подзадача становится корнем.
+        {"orphan becomes root", []Task{testTask(1, 0, 0, "todo"), testTask(5, 99, 0, "todo")}, "1 5"},
+        {"orphan keeps its children", []Task{testTask(5, 99, 0, "todo"), testTask(6, 5, 0, "todo"), testTask(1, 0, 0, "todo")}, "5(6) 1"},
+        {"empty list", []Task{}, ""},
+    }
+    for _, c := range cases {
+        if got := treeShape(buildTaskTree(c.tasks)); got != c.want {
+            t.Errorf("%s: buildTaskTree = %q, want %q", c.name, got, c.want)
+        }
+    }
+}
+
+func TestTaskProgress(t *testing.T) {
+    cases := []struct {
+        name  string
+        tasks []Task
+        id    int
+        want  float64
+    }{
+        {"open leaf", []Task{testTask(1, 0, 0, "todo")}, 1, 0},
+        {"done leaf", []Task{testTask(1, 0, 0, "done")}, 1, 100},
+        {"half of the children done", []Task{
+            testTask(1, 0, 0, "in_progress"), testTask(2, 1, 0, "done"), testTask(3, 1, 1, "todo"),
+        }, 1, 50},
+        {"one of three done", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "done"), testTask(3, 1, 1, "todo"), testTask(4, 1, 2, "blocked"),
+        }, 1, 33.33},
+        {"cancelled children are skipped", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "done"), testTask(3, 1, 1, "cancelled"),
+        }, 1, 100},
+        {"only cancelled children", []Task{testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "cancelled")}, 1, 0},
+        {"done parent is complete whatever its children", []Task{testTask(1, 0, 0, "done"), testTask(2, 1, 0, "todo")}, 1, 100},
+        // Средний уровень сделан наполовину, поэтому корень — на четверть.
+        {"nested partial progress", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "todo"), testTask(3, 1, 1, "todo"),
+            testTask(4, 2, 0, "done"), testTask(5, 2, 1, "todo"),
+        }, 1, 25},
+        {"middle of the tree", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "todo"), testTask(4, 2, 0, "done"), testTask(5, 2, 1, "todo"),
+        }, 2, 50},
+        // Без части подзадач в выборке прогресс считается по тому, что в неё попало.
+        {"partial tree counts loaded children", []Task{
+            testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "done"),
+        }, 1, 100},
+    }
+    for _, c := range cases {
+        task := findTask(buildTaskTree(c.tasks), c.id)
+        if task == nil {
+            t.Errorf("%s: task %d not in tree", c.name, c.id)
+            continue
+        }
+        if task.Progress != c.want {
+            t.Errorf("%s: progress = %v, want %v", c.name, task.Progress, c.want)
+        }
+    }
+}
+
+func TestApplyTaskProgress(t *testing.T) {
+    tasks := []Task{
+        testTask(1, 0, 0, "todo"), testTask(2, 1, 0, "done"), testTask(3, 1, 1, "todo"), testTask(4, 99, 0, "todo"),
+    }
+    applyTaskProgress(tasks)
+
+    want := map[int]float64{1: 50, 2: 100, 3: 0, 4: 0}
+    for _, task := range tasks {
+        if task.Progress != want[task.ID] {
+            t.Errorf("task %d: progress = %v, want %v", task.ID, task.Progress, want[task.ID])
+        }
+    }
+}
+
+func TestFilterTasksByStatusKeepsSubtreeProgress(t *testing.T) {
+    tasks := []Task{
+        testTask(1, 0, 0, "in_progress"), testTask(2, 1, 0, "done"), testTask(3, 1, 1, "todo"),
+        testTask(4, 0, 1, "todo"), testTask(5, 4, 0, "in_progress"),
+    }
+
+    // Дерево фильтруется по корням, а прогресс корня учитывает подзадачи любого статуса.
+    tree := filterTasksByStatus(buildTaskTree(tasks), "in_progress")
+    if got := treeShape(tree); got != "1(2 3)" {
+        t.Fatalf("filtered tree = %q, want %q", got, "1(2 3)")
+    }
+    if tree[0].Progress != 50 {
+        t.Errorf("filtered root progress = %v, want 50", tree[0].Progress)
+    }
+
+    // В плоском списке подходящая подзадача остаётся, прогресс посчитан по всем задачам.
+    applyTaskProgress(tasks)
+    flat := filterTasksByStatus(tasks, "in_progress")
+    if len(flat) != 2 || flat[0].ID != 1 || flat[1].ID != 5 {
+        t.Fatalf("filtered flat list = %v, want tasks 1 and 5", treeShape(flat))
+    }
+    if flat[0].Progress != 50 {
+        t.Errorf("filtered flat progress = %v, want 50", flat[0].Progress)
+    }
+
+    if got := filterTasksByStatus(tasks, ""); len(got) != len(tasks) {
+        t.Errorf("empty status kept %d tasks, want %d", len(got), len(tasks))
+    }
+}
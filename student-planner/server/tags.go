@@ -0,0 +1,324 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+    "github.com/lib/pq"
+)
+
+// Tag — тег пользователя. Теги, встроенные в события и задачи, собираются
+// в JSON без user_id и created_at, и эти поля там опускаются.
+type Tag struct {
+    ID        int        `json:"id"`
+    UserID    int        `json:"user_id,omitempty"`
+    Name      string     `json:"name"`
+    Color     string     `json:"color"`
+    CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+const tagColumns = `id, user_id, name, COALESCE(color, ''), created_at`
+
+// eventTagsColumn и taskTagsColumn собирают теги строки в JSON-массив.
+const eventTagsColumn = `COALESCE((SELECT json_agg(json_build_object('id', tg.id, 'name', tg.name, 'color', COALESCE(tg.color, '')) ORDER BY tg.name)
+                FROM tags tg JOIN event_tags l ON l.tag_id = tg.id WHERE l.event_id = events.id), '[]')`
+
+const taskTagsColumn = `COALESCE((SELECT json_agg(json_build_object('id', tg.id, 'name', tg.name, 'color', COALESCE(tg.color, '')) ORDER BY tg.name)
+                FROM tags tg JOIN task_tags l ON l.tag_id = tg.id WHERE l.task_id = tasks.id), '[]')`
+
+func tagsFromJSON(data []byte) []Tag {
+    tags := []Tag{}
+    if len(data) > 0 {
+        json.Unmarshal(data, &tags)
+    }
+    return tags
+}
+
+func scanTag(row rowScanner) (Tag, error) {
+    var tag Tag
+    err := row.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt)
+    return tag, err
+}
+
+// tagFilter разбирает ?tag_id=1,2 (или повторяющийся параметр) списочных запросов.
+func tagFilter(r *http.Request) []int64 {
+    ids := []int64{}
+    for _, value := range r.URL.Query()["tag_id"] {
+        for _, part := range strings.Split(value, ",") {
+            if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && id > 0 {
+                ids = append(ids, int64(id))
+            }
+        }
+    }
+    return ids
+}
+
+// appendTagFilter оставляет строки, помеченные хотя бы одним из тегов.
+func appendTagFilter(query string, args []interface{}, r *http.Request, link, owner string) (string, []interface{}) {
+    ids := tagFilter(r)
+    if len(ids) == 0 {
+        return query, args
+    }
+
+    args = append(args, pq.Int64Array(ids))
+    query += fmt.Sprintf(" AND id IN (SELECT %s FROM %s WHERE tag_id = ANY($%d))", owner, link, len(args))
+    return query, args
+}
+
+// sqlExecer — общее у *sql.DB и *sql.Tx, чтобы запись работала и внутри транзакции.
+type sqlExecer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// sqlQueryer — то же для чтения: проверки внутри пакетной операции должны видеть
+// строки, ещё не зафиксированные транзакцией.
+type sqlQueryer interface {
+    sqlExecer
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// replaceTags заменяет теги строки; чужие и несуществующие теги молча пропускаются.
+func replaceTags(link, owner string, ownerID, userID int, tagIDs []int) error {
+    return replaceTagsWith(db, link, owner, ownerID, userID, tagIDs)
+}
+
+func replaceTagsWith(q sqlExecer, link, owner string, ownerID, userID int, tagIDs []int) error {
+    if tagIDs == nil {
+        return nil
+    }
+
+    if _, err := q.Exec("DELETE FROM "+link+" WHERE "+owner+" = $1", ownerID); err != nil {
+        return err
+    }
+    if len(tagIDs) == 0 {
+        return nil
+    }
+
+    ids := make(pq.Int64Array, 0, len(tagIDs))
+    for _, id := range tagIDs {
+        ids = append(ids, int64(id))
+    }
+
+    _, err := q.Exec(
+        "INSERT INTO "+link+" ("+owner+", tag_id) SELECT $1, id FROM tags WHERE user_id = $2 AND id = ANY($3) ON CONFLICT DO NOTHING",
+        ownerID, userID, ids,
+    )
+    return err
+}
+
+func GetTags(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    rows, err := db.Query(`SELECT `+tagColumns+` FROM tags WHERE user_id = $1 ORDER BY name`, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения тегов"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    tags := []Tag{}
+    for rows.Next() {
+        tag, err := scanTag(rows)
+        if err != nil {
+            continue
+        }
+        tags = append(tags, tag)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(tags)
+}
+
+func CreateTag(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Name  string `json:"name"`
+        Color string `json:"color"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        http.Error(w, `{"error": "Введите название тега"}`, http.StatusBadRequest)
+        return
+    }
+
+    tag, err := scanTag(db.QueryRow(
+        `INSERT INTO tags (user_id, name, color) VALUES ($1, $2, $3)
+         ON CONFLICT (user_id, name) DO NOTHING
+         RETURNING `+tagColumns,
+        userID, req.Name, req.Color,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Тег с таким названием уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка создания тега"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(tag)
+}
+
+func UpdateTag(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    tagID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Name  string `json:"name"`
+        Color string `json:"color"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        http.Error(w, `{"error": "Введите название тега"}`, http.StatusBadRequest)
+        return
+    }
+
+    tag, err := scanTag(db.QueryRow(
+        `UPDATE tags SET name = $1, color = $2
+         WHERE id = $3 AND user_id = $4
+         RETURNING `+tagColumns,
+        req.Name, req.Color, tagID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Тег не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if isUniqueViolation(err) {
+        http.Error(w, `{"error": "Тег с таким названием уже существует"}`, http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления тега"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(tag)
+}
+
+func DeleteTag(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    tagID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM tags WHERE id = $1 AND user_id = $2", tagID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления тега"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Тег не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Тег удален"})
+}
+
+func GetTagStats(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    type TagStats struct {
+        TagID          int     `json:"tag_id"`
+        Name           string  `json:"name"`
+        Color          string  `json:"color"`
+        TotalEvents    int     `json:"total_events"`
+        StudyHours     float64 `json:"study_hours"`
+        TotalTasks     int     `json:"total_tasks"`
+        CompletedTasks int     `json:"completed_tasks"`
+    }
+
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+
+    eventWhere, args := appendEventTermFilter("user_id = $1 AND deleted_at IS NULL", []interface{}{userID}, term, userLocation(userID))
+    taskWhere, args := appendTaskTermFilter("user_id = $1 AND deleted_at IS NULL", args, term)
+
+    rows, err := db.Query(
+        `SELECT tg.id, tg.name, COALESCE(tg.color, ''),
+                COALESCE(e.total, 0), COALESCE(e.hours, 0),
+                COALESCE(t.total, 0), COALESCE(t.completed, 0)
+         FROM tags tg
+         LEFT JOIN (SELECT et.tag_id, COUNT(*) AS total, SUM(ev.duration_hours) AS hours
+                    FROM event_tags et JOIN (SELECT id, duration_hours FROM events WHERE `+eventWhere+`) ev
+                         ON ev.id = et.event_id
+                    GROUP BY et.tag_id) e ON e.tag_id = tg.id
+         LEFT JOIN (SELECT tt.tag_id, COUNT(*) AS total,
+                           COUNT(*) FILTER (WHERE tk.is_completed) AS completed
+                    FROM task_tags tt JOIN (SELECT id, is_completed FROM tasks WHERE `+taskWhere+`) tk
+                         ON tk.id = tt.task_id
+                    GROUP BY tt.tag_id) t ON t.tag_id = tg.id
+         WHERE tg.user_id = $1
+         ORDER BY tg.name`,
+        args...,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения статистики"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    stats := []TagStats{}
+    for rows.Next() {
+        var item TagStats
+        err := rows.Scan(
+            &item.TagID, &item.Name, &item.Color, &item.TotalEvents, &item.StudyHours,
+            &item.TotalTasks, &item.CompletedTasks,
+        )
+        if err != nil {
+            continue
+        }
+        stats = append(stats, item)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gorilla/mux"
+)
+
+// taskStatuses — колонки доски задач в порядке отображения.
+var taskStatuses = []string{"todo", "in_progress", "blocked", "done", "cancelled"}
+
+// taskTransitions перечисляет допустимые переходы между статусами задачи.
+var taskTransitions = map[string][]string{
+    "todo":        {"in_progress", "blocked", "done", "cancelled"},
+    "in_progress": {"todo", "blocked", "done", "cancelled"},
+    "blocked":     {"todo", "in_progress", "cancelled"},
+    "done":        {"todo", "in_progress"},
+    "cancelled":   {"todo"},
+}
+
+func canTransition(from, to string) bool {
+    for _, status := range taskTransitions[from] {
+        if status == to {
+            return true
+        }
+    }
+    return false
+}
+
+// completionStatus — статус, в который задачу из current переводит отметка о выполнении:
+// отметка ставит done, снятие отметки возвращает выполненную задачу в todo.
+func completionStatus(current string, completed bool) string {
+    if completed {
+        return "done"
+    }
+    if current == "done" {
+        return "todo"
+    }
+    return current
+}
+
+// transitionError — отказ в смене статуса: переход не разрешён таблицей
+// или у задачи есть невыполненные предпосылки.
+type transitionError struct {
+    from      string
+    to        string
+    blockedBy []int
+}
+
+func (e *transitionError) Error() string {
+    if len(e.blockedBy) > 0 {
+        return fmt.Sprintf("Задача заблокирована невыполненными задачами: %v", e.blockedBy)
+    }
+    return fmt.Sprintf("Нельзя перевести задачу из статуса %s в %s", e.from, e.to)
+}
+
+// checkTaskTransition проверяет, можно ли перевести задачу владельца ownerID из from в to.
+// При переходе в done без force у задач из scope (см. openPrerequisites) не должно быть
+// невыполненных предпосылок. Отказ возвращается как *transitionError.
+func checkTaskTransition(q sqlQueryer, taskID, ownerID int, from, to, scope string, force bool) error {
+    if from == to {
+        return nil
+    }
+    if !canTransition(from, to) {
+        return &transitionError{from: from, to: to}
+    }
+    if to == "done" && !force {
+        blockedBy, err := openPrerequisites(q, taskID, ownerID, scope)
+        if err != nil {
+            return err
+        }
+        if len(blockedBy) > 0 {
+            return &transitionError{from: from, to: to, blockedBy: blockedBy}
+        }
+    }
+    return nil
+}
+
+// writeTransitionError отвечает 409 на отказ checkTaskTransition.
+func writeTransitionError(w http.ResponseWriter, e *transitionError) {
+    if len(e.blockedBy) > 0 {
+        writeBlockedBy(w, e.blockedBy)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusConflict)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "error":   e.Error(),
+        "allowed": taskTransitions[e.from],
+    })
+}
+
+// taskStatus возвращает текущий статус задачи владельца ownerID. В транзакции строка
+// остаётся заблокированной до её конца, так что проверка перехода и запись видят один
+// и тот же статус.
+func taskStatus(q sqlQueryer, taskID, ownerID int) (string, error) {
+    var status string
+    err := q.QueryRow(
+        "SELECT status FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE",
+        taskID, ownerID,
+    ).Scan(&status)
+    return status, err
+}
+
+// taskStatusAssignments возвращает SET-часть UPDATE, переводящую задачу в статус status
+// (SQL-выражение) и поддерживающую is_completed, started_at и completed_at.
+func taskStatusAssignments(status string) string {
+    return fmt.Sprintf(`status = %[1]s,
+             is_completed = (%[1]s) = 'done',
+             started_at = CASE WHEN (%[1]s) = 'todo' THEN NULL
+                               WHEN (%[1]s) IN ('in_progress', 'done') THEN COALESCE(started_at, CURRENT_TIMESTAMP)
+                               ELSE started_at END,
+             completed_at = CASE WHEN (%[1]s) = 'done' THEN COALESCE(completed_at, CURRENT_TIMESTAMP) END`,
+        status,
+    )
+}
+
+func UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    taskID, _ := strconv.Atoi(vars["id"])
+
+    var req struct {
+        Status string `json:"status"`
+        Force  bool   `json:"force"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if _, ok := taskTransitions[req.Status]; !ok {
+        writeBadRequest(w, "Статус должен быть todo, in_progress, blocked, done или cancelled")
+        return
+    }
+
+    version, ok := checkIfMatch(w, r, "task", taskID, userID)
+    if !ok {
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления статуса"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    // Дальше задача меняется от имени владельца: доступ мог быть выдан через share.
+    // Строка заблокирована до конца транзакции, чтобы параллельный запрос не сменил
+    // статус между проверкой перехода и записью.
+    var ownerID int
+    var current string
+    err = tx.QueryRow(
+        "SELECT user_id, status FROM tasks WHERE id = $1 AND "+editableTasksScope("$2")+" AND deleted_at IS NULL FOR UPDATE",
+        taskID, userID,
+    ).Scan(&ownerID, &current)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления статуса"}`, http.StatusInternalServerError)
+        return
+    }
+
+    err = checkTaskTransition(tx, taskID, ownerID, current, req.Status, "SELECT $1::int", req.Force)
+    if transition, ok := err.(*transitionError); ok {
+        writeTransitionError(w, transition)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки зависимостей"}`, http.StatusInternalServerError)
+        return
+    }
+
+    before := snapshotRow("task", taskID)
+    result, err := tx.Exec(
+        `UPDATE tasks SET `+taskStatusAssignments("$1::varchar")+`
+         WHERE id = $2 AND user_id = $3 AND `+versionCondition("$4"),
+        req.Status, taskID, ownerID, version,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления статуса"}`, http.StatusInternalServerError)
+        return
+    }
+    if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 && version != 0 {
+        writeVersionConflict(w, currentVersion("task", taskID))
+        return
+    } else if rowsAffected == 0 {
+        http.Error(w, `{"error": "Задача не найдена или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка обновления статуса"}`, http.StatusInternalServerError)
+        return
+    }
+    recordHistory("task", taskID, userID, "status", before)
+
+    if req.Status == "done" {
+        if err := spawnNextOccurrence(taskID, ownerID); err != nil {
+            http.Error(w, `{"error": "Задача выполнена, но следующее повторение не создано"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+
+    task, err := loadTaskTree(taskID, ownerID)
+    if err != nil {
+        http.Error(w, `{"error": "Статус задачи обновлен, но не получен"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("ETag", versionETag(task.Version))
+    json.NewEncoder(w).Encode(task)
+}
+
+// GetTaskBoard группирует задачи по статусам для канбан-доски.
+// Подзадачи остаются внутри родителя и на доску отдельно не выносятся.
+func GetTaskBoard(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    type BoardColumn struct {
+        Status string `json:"status"`
+        Count  int    `json:"count"`
+        Tasks  []Task `json:"tasks"`
+    }
+
+    term, err := termScope(r, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения задач"}`, http.StatusInternalServerError)
+        return
+    }
+
+    query := `SELECT ` + taskColumns + ` FROM tasks WHERE user_id = $1 AND deleted_at IS NULL`
+    args := []interface{}{userID}
+    if subjectID, ok := subjectFilter(r); ok {
+        args = append(args, subjectID)
+        query += fmt.Sprintf(" AND subject_id = $%d", len(args))
+    }
+    query, args = appendTaskTermFilter(query, args, term)
+    query, args = appendTagFilter(query, args, r, "task_tags", "task_id")
+    query, args = appendDeadlineFilter(query, args, r)
+    query += " ORDER BY " + taskDeadlineColumn + ", priority, position"
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения задач"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    tasks := []Task{}
+    for rows.Next() {
+        task, err := scanTask(rows)
+        if err != nil {
+            continue
+        }
+        tasks = append(tasks, task)
+    }
+
+    byStatus := make(map[string][]Task)
+    for _, task := range buildTaskTree(tasks) {
+        byStatus[task.Status] = append(byStatus[task.Status], task)
+    }
+
+    board := []BoardColumn{}
+    for _, status := range taskStatuses {
+        column := BoardColumn{Status: status, Tasks: byStatus[status]}
+        if column.Tasks == nil {
+            column.Tasks = []Task{}
+        }
+        column.Count = len(column.Tasks)
+        board = append(board, column)
+    }
+
+    writeJSONWithETag(w, r, "", board)
+}
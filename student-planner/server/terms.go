@@ -0,0 +1,417 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+var errTermNotFound = errors.New("семестр не найден")
+
+type TermHoliday struct {
+    Name      string `json:"name"`
+    StartDate string `json:"start_date"`
+    EndDate   string `json:"end_date"`
+}
+
+type Term struct {
+    ID            int           `json:"id"`
+    UserID        int           `json:"user_id"`
+    Name          string        `json:"name"`
+    StartDate     string        `json:"start_date"`
+    EndDate       string        `json:"end_date"`
+    ExamStartDate string        `json:"exam_start_date"`
+    ExamEndDate   string        `json:"exam_end_date"`
+    Holidays      []TermHoliday `json:"holidays"`
+    IsArchived    bool          `json:"is_archived"`
+    IsActive      bool          `json:"is_active"`
+    CreatedAt     time.Time     `json:"created_at"`
+}
+
+type termInput struct {
+    Name          string        `json:"name"`
+    StartDate     string        `json:"start_date"`
+    EndDate       string        `json:"end_date"`
+    ExamStartDate string        `json:"exam_start_date"`
+    ExamEndDate   string        `json:"exam_end_date"`
+    Holidays      []TermHoliday `json:"holidays"`
+}
+
+const termColumns = `id, user_id, name, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'),
+                COALESCE(to_char(exam_start_date, 'YYYY-MM-DD'), ''), COALESCE(to_char(exam_end_date, 'YYYY-MM-DD'), ''),
+                holidays, is_archived,
+                EXISTS (SELECT 1 FROM users WHERE users.id = terms.user_id AND users.active_term_id = terms.id),
+                created_at`
+
+func scanTerm(row rowScanner) (Term, error) {
+    var term Term
+    var holidays []byte
+    err := row.Scan(
+        &term.ID, &term.UserID, &term.Name, &term.StartDate, &term.EndDate,
+        &term.ExamStartDate, &term.ExamEndDate, &holidays, &term.IsArchived,
+        &term.IsActive, &term.CreatedAt,
+    )
+    if err != nil {
+        return term, err
+    }
+
+    term.Holidays = []TermHoliday{}
+    if len(holidays) > 0 {
+        json.Unmarshal(holidays, &term.Holidays)
+    }
+    return term, nil
+}
+
+// validate проверяет даты семестра и возвращает текст ошибки для клиента.
+func (req *termInput) validate() string {
+    req.Name = strings.TrimSpace(req.Name)
+    if req.Name == "" {
+        return "Введите название семестра"
+    }
+
+    start, err := time.Parse("2006-01-02", req.StartDate)
+    if err != nil {
+        return "Неверная дата начала семестра"
+    }
+    end, err := time.Parse("2006-01-02", req.EndDate)
+    if err != nil || end.Before(start) {
+        return "Неверная дата окончания семестра"
+    }
+
+    if req.ExamStartDate != "" || req.ExamEndDate != "" {
+        examStart, err1 := time.Parse("2006-01-02", req.ExamStartDate)
+        examEnd, err2 := time.Parse("2006-01-02", req.ExamEndDate)
+        if err1 != nil || err2 != nil || examEnd.Before(examStart) {
+            return "Неверные даты сессии"
+        }
+        // Выборки по семестру ограничены его датами, поэтому сессия, как и каникулы,
+        // должна лежать внутри семестра, иначе её события и задачи в них не попадут.
+        if examStart.Before(start) || examEnd.After(end) {
+            return "Сессия должна быть внутри семестра"
+        }
+    }
+
+    if req.Holidays == nil {
+        req.Holidays = []TermHoliday{}
+    }
+    for _, holiday := range req.Holidays {
+        holidayStart, err1 := time.Parse("2006-01-02", holiday.StartDate)
+        holidayEnd, err2 := time.Parse("2006-01-02", holiday.EndDate)
+        if err1 != nil || err2 != nil || holidayEnd.Before(holidayStart) ||
+            holidayStart.Before(start) || holidayEnd.After(end) {
+            return "Каникулы должны быть внутри семестра"
+        }
+    }
+    return ""
+}
+
+// bounds возвращает границы семестра в часовом поясе пользователя: [начало, конец).
+func (t *Term) bounds(loc *time.Location) (time.Time, time.Time) {
+    start, _ := time.ParseInLocation("2006-01-02", t.StartDate, loc)
+    end, _ := time.ParseInLocation("2006-01-02", t.EndDate, loc)
+    return start, end.AddDate(0, 0, 1)
+}
+
+func loadTerm(termID, userID int) (*Term, error) {
+    term, err := scanTerm(db.QueryRow(
+        `SELECT `+termColumns+` FROM terms WHERE id = $1 AND user_id = $2`,
+        termID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        return nil, errTermNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &term, nil
+}
+
+// termScope определяет семестр, которым ограничивается выборка.
+// Без параметра используется активный семестр пользователя, ?term_id=all снимает ограничение.
+func termScope(r *http.Request, userID int) (*Term, error) {
+    param := r.URL.Query().Get("term_id")
+    if param == "all" {
+        return nil, nil
+    }
+
+    if param == "" || param == "active" {
+        var activeTermID sql.NullInt64
+        err := db.QueryRow("SELECT active_term_id FROM users WHERE id = $1", userID).Scan(&activeTermID)
+        if err != nil || !activeTermID.Valid {
+            return nil, nil
+        }
+        return loadTerm(int(activeTermID.Int64), userID)
+    }
+
+    termID, err := strconv.Atoi(param)
+    if err != nil || termID <= 0 {
+        return nil, errTermNotFound
+    }
+    return loadTerm(termID, userID)
+}
+
+func appendEventTermFilter(query string, args []interface{}, term *Term, loc *time.Location) (string, []interface{}) {
+    if term == nil {
+        return query, args
+    }
+
+    start, end := term.bounds(loc)
+    args = append(args, start, end)
+    query += fmt.Sprintf(" AND starts_at >= $%d AND starts_at < $%d", len(args)-1, len(args))
+    return query, args
+}
+
+// appendTaskTermFilter относит задачу к семестру по сроку, а задачу без срока — по дате создания.
+func appendTaskTermFilter(query string, args []interface{}, term *Term) (string, []interface{}) {
+    if term == nil {
+        return query, args
+    }
+
+    args = append(args, term.StartDate, term.EndDate)
+    query += fmt.Sprintf(" AND COALESCE(due_date, created_at::date) BETWEEN $%d AND $%d", len(args)-1, len(args))
+    return query, args
+}
+
+func GetTerms(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    query := `SELECT ` + termColumns + ` FROM terms WHERE user_id = $1`
+    if r.URL.Query().Get("archived") != "true" {
+        query += ` AND NOT is_archived`
+    }
+    query += ` ORDER BY start_date DESC`
+
+    rows, err := db.Query(query, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения семестров"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    terms := []Term{}
+    for rows.Next() {
+        term, err := scanTerm(rows)
+        if err != nil {
+            continue
+        }
+        terms = append(terms, term)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(terms)
+}
+
+func CreateTerm(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req termInput
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if msg := req.validate(); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    holidays, _ := json.Marshal(req.Holidays)
+    term, err := scanTerm(db.QueryRow(
+        `INSERT INTO terms (user_id, name, start_date, end_date, exam_start_date, exam_end_date, holidays)
+         VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, NULLIF($6, '')::date, $7)
+         RETURNING `+termColumns,
+        userID, req.Name, req.StartDate, req.EndDate, req.ExamStartDate, req.ExamEndDate, holidays,
+    ))
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания семестра"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(term)
+}
+
+func UpdateTerm(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    termID, _ := strconv.Atoi(vars["id"])
+
+    var req termInput
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    if msg := req.validate(); msg != "" {
+        writeBadRequest(w, msg)
+        return
+    }
+
+    holidays, _ := json.Marshal(req.Holidays)
+    term, err := scanTerm(db.QueryRow(
+        `UPDATE terms
+         SET name = $1, start_date = $2, end_date = $3, exam_start_date = NULLIF($4, '')::date,
+             exam_end_date = NULLIF($5, '')::date, holidays = $6
+         WHERE id = $7 AND user_id = $8
+         RETURNING `+termColumns,
+        req.Name, req.StartDate, req.EndDate, req.ExamStartDate, req.ExamEndDate, holidays,
+        termID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Семестр не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка обновления семестра"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(term)
+}
+
+func DeleteTerm(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    termID, _ := strconv.Atoi(vars["id"])
+
+    result, err := db.Exec("DELETE FROM terms WHERE id = $1 AND user_id = $2", termID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка удаления семестра"}`, http.StatusInternalServerError)
+        return
+    }
+
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        http.Error(w, `{"error": "Семестр не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Семестр удален"})
+}
+
+func ActivateTerm(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    termID, _ := strconv.Atoi(vars["id"])
+
+    term, err := loadTerm(termID, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения семестра"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if term.IsArchived {
+        http.Error(w, `{"error": "Архивный семестр нельзя сделать активным"}`, http.StatusBadRequest)
+        return
+    }
+
+    if _, err := db.Exec("UPDATE users SET active_term_id = $1 WHERE id = $2", termID, userID); err != nil {
+        http.Error(w, `{"error": "Ошибка выбора активного семестра"}`, http.StatusInternalServerError)
+        return
+    }
+    term.IsActive = true
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(term)
+}
+
+func ArchiveTerm(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    termID, _ := strconv.Atoi(vars["id"])
+
+    term, err := loadTerm(termID, userID)
+    if err == errTermNotFound {
+        http.Error(w, `{"error": "Семестр не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка получения семестра"}`, http.StatusInternalServerError)
+        return
+    }
+
+    today := dateOnly(time.Now(), userLocation(userID)).Format("2006-01-02")
+    if term.EndDate >= today {
+        http.Error(w, `{"error": "Архивировать можно только завершившийся семестр"}`, http.StatusBadRequest)
+        return
+    }
+
+    if _, err := db.Exec("UPDATE terms SET is_archived = TRUE WHERE id = $1", termID); err != nil {
+        http.Error(w, `{"error": "Ошибка архивирования семестра"}`, http.StatusInternalServerError)
+        return
+    }
+    db.Exec("UPDATE users SET active_term_id = NULL WHERE id = $1 AND active_term_id = $2", userID, termID)
+
+    term.IsArchived = true
+    term.IsActive = false
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(term)
+}
+
+func UnarchiveTerm(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    termID, _ := strconv.Atoi(vars["id"])
+
+    term, err := scanTerm(db.QueryRow(
+        `UPDATE terms SET is_archived = FALSE
+         WHERE id = $1 AND user_id = $2
+         RETURNING `+termColumns,
+        termID, userID,
+    ))
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Семестр не найден или нет прав доступа"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления семестра"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(term)
+}
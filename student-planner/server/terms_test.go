@@ -0,0 +1,56 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestTermInputValidate(t *testing.T) {
+    cases := []struct {
+        name      string
+        examStart string
+        examEnd   string
+        holidays  []TermHoliday
+        want      string
+    }{
+        {"no exams", "", "", nil, ""},
+        {"exams inside term", "2025-01-09", "2025-01-25", nil, ""},
+        {"exams on term edges", "2024-09-01", "2025-01-31", nil, ""},
+        {"exams after term end", "2025-01-20", "2025-02-05", nil, "Сессия должна быть внутри семестра"},
+        {"exams before term start", "2024-08-25", "2024-09-10", nil, "Сессия должна быть внутри семестра"},
+        {"exam end before start", "2025-01-20", "2025-01-10", nil, "Неверные даты сессии"},
+        {"only exam start", "2025-01-20", "", nil, "Неверные даты сессии"},
+        {"holiday inside term", "", "", []TermHoliday{{"Новый год", "2024-12-30", "2025-01-08"}}, ""},
+        {"holiday after term end", "", "", []TermHoliday{{"Каникулы", "2025-01-30", "2025-02-08"}}, "Каникулы должны быть внутри семестра"},
+    }
+    for _, c := range cases {
+        req := termInput{
+            Name:          " Осень 2024 ",
+            StartDate:     "2024-09-01",
+            EndDate:       "2025-01-31",
+            ExamStartDate: c.examStart,
+            ExamEndDate:   c.examEnd,
+            Holidays:      c.holidays,
+        }
+        if got := req.validate(); got != c.want {
+            t.Errorf("%s: validate = %q, want %q", c.name, got, c.want)
+        }
+    }
+}
+
+func TestTermBounds(t *testing.T) {
+    loc, err := time.LoadLocation("Europe/Moscow")
+    if err != nil {
+        t.Skip("нет базы часовых поясов")
+    }
+    term := Term{StartDate: "2024-09-01", EndDate: "2025-01-31", ExamStartDate: "2025-01-09", ExamEndDate: "2025-01-31"}
+
+    start, end := term.bounds(loc)
+    if want := time.Date(2024, 9, 1, 0, 0, 0, 0, loc); !start.Equal(want) {
+        t.Errorf("start = %v, want %v", start, want)
+    }
+    // Последний день семестра, а с ним и сессии, входит в выборку целиком.
+    if want := time.Date(2025, 2, 1, 0, 0, 0, 0, loc); !end.Equal(want) {
+        t.Errorf("end = %v, want %v", end, want)
+    }
+}
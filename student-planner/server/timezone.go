@@ -0,0 +1,93 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "time"
+)
+
+const defaultTimezone = "UTC"
+
+var errBadEventStart = errors.New("неверная дата или время начала события")
+
+// userLocation возвращает часовой пояс из настроек пользователя.
+// Если пояс не задан или неизвестен, используется UTC.
+func userLocation(userID int) *time.Location {
+    var tz string
+    if err := db.QueryRow("SELECT timezone FROM users WHERE id = $1", userID).Scan(&tz); err != nil {
+        return time.UTC
+    }
+    return locationOrUTC(tz)
+}
+
+// locationOrUTC загружает пояс по имени; пустое или неизвестное имя даёт UTC.
+func locationOrUTC(tz string) *time.Location {
+    if !validTimezone(tz) {
+        return time.UTC
+    }
+    loc, err := time.LoadLocation(tz)
+    if err != nil {
+        return time.UTC
+    }
+    return loc
+}
+
+// requestLocation позволяет переопределить часовой пояс пользователя параметром ?tz=.
+func requestLocation(r *http.Request, userID int) (*time.Location, error) {
+    if tz := r.URL.Query().Get("tz"); tz != "" {
+        return time.LoadLocation(tz)
+    }
+    return userLocation(userID), nil
+}
+
+func validTimezone(tz string) bool {
+    if tz == "" || tz == "Local" {
+        return false
+    }
+    _, err := time.LoadLocation(tz)
+    return err == nil
+}
+
+// parseEventStart переводит локальные дату и время пользователя в момент времени.
+// Время в "дыре" перехода на летнее время сдвигается вперёд на длину дыры (02:30 — в 03:30
+// по новому времени), а повторяющееся при переходе на зимнее относится к первому проходу.
+func parseEventStart(startsAt, date, clock string, loc *time.Location) (time.Time, error) {
+    if startsAt != "" {
+        t, err := time.Parse(time.RFC3339, startsAt)
+        if err != nil {
+            return time.Time{}, errBadEventStart
+        }
+        return t, nil
+    }
+
+    if date == "" || clock == "" {
+        return time.Time{}, errBadEventStart
+    }
+
+    for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+        t, err := time.ParseInLocation(layout, date+" "+clock, loc)
+        if err != nil {
+            continue
+        }
+        // Go считает несуществующее время по новому смещению, и часы уходят назад:
+        // 02:30 становится 01:30 по старому времени. Возвращаем их на длину дыры.
+        wall, _ := time.Parse(layout, date+" "+clock)
+        if gap := wall.Sub(wallClock(t)); gap > 0 {
+            t = t.Add(gap)
+        }
+        return t, nil
+    }
+    return time.Time{}, errBadEventStart
+}
+
+// wallClock — показания часов в поясе t, записанные как время UTC, для сравнения с разобранными.
+func wallClock(t time.Time) time.Time {
+    return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// localDayBounds возвращает начало дня from и начало дня, следующего за to, в поясе loc.
+func localDayBounds(from, to time.Time, loc *time.Location) (time.Time, time.Time) {
+    start := dateOnly(from, loc)
+    end := dateOnly(to, loc).AddDate(0, 0, 1)
+    return start, end
+}
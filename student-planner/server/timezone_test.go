@@ -0,0 +1,114 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func loadTestLocation(t *testing.T, name string) *time.Location {
+    loc, err := time.LoadLocation(name)
+    if err != nil {
+        t.Skipf("нет пояса %s: %v", name, err)
+    }
+    return loc
+}
+
+func TestParseEventStart(t *testing.T) {
+    newYork := loadTestLocation(t, "America/New_York")
+    moscow := loadTestLocation(t, "Europe/Moscow")
+
+    cases := []struct {
+        name     string
+        startsAt string
+        date     string
+        clock    string
+        loc      *time.Location
+        want     time.Time
+        wantErr  bool
+    }{
+        {"starts_at wins over local fields", "2024-09-02T09:00:00+03:00", "2024-09-03", "10:00", newYork,
+            time.Date(2024, 9, 2, 6, 0, 0, 0, time.UTC), false},
+        {"local time", "", "2024-09-02", "09:00", moscow,
+            time.Date(2024, 9, 2, 6, 0, 0, 0, time.UTC), false},
+        {"local time with seconds", "", "2024-09-02", "09:00:30", moscow,
+            time.Date(2024, 9, 2, 6, 0, 30, 0, time.UTC), false},
+        // 2024-03-10 02:00 EST часы переводятся на 03:00 EDT: 02:30 не существует.
+        {"spring-forward gap moves forward", "", "2024-03-10", "02:30", newYork,
+            time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC), false},
+        {"right after the gap", "", "2024-03-10", "03:00", newYork,
+            time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), false},
+        // 2024-11-03 01:00–02:00 проходит дважды: сначала по EDT, потом по EST.
+        {"fall-back overlap takes first pass", "", "2024-11-03", "01:30", newYork,
+            time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), false},
+        {"after the overlap", "", "2024-11-03", "02:30", newYork,
+            time.Date(2024, 11, 3, 7, 30, 0, 0, time.UTC), false},
+        {"UTC location", "", "2024-03-10", "02:30", locationOrUTC(""),
+            time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC), false},
+        {"bad starts_at", "2024-09-02 09:00", "", "", moscow, time.Time{}, true},
+        {"missing clock", "", "2024-09-02", "", moscow, time.Time{}, true},
+        {"bad clock", "", "2024-09-02", "9 утра", moscow, time.Time{}, true},
+    }
+    for _, c := range cases {
+        got, err := parseEventStart(c.startsAt, c.date, c.clock, c.loc)
+        if (err != nil) != c.wantErr {
+            t.Errorf("%s: err = %v, want error %v", c.name, err, c.wantErr)
+            continue
+        }
+        if !got.Equal(c.want) {
+            t.Errorf("%s: parseEventStart = %v, want %v", c.name, got.UTC(), c.want)
+        }
+    }
+}
+
+func TestLocalDayBounds(t *testing.T) {
+    newYork := loadTestLocation(t, "America/New_York")
+
+    cases := []struct {
+        name      string
+        from      time.Time
+        to        time.Time
+        loc       *time.Location
+        wantStart time.Time
+        wantLen   time.Duration
+    }{
+        {"ordinary day", time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC), time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC), newYork,
+            time.Date(2024, 9, 2, 4, 0, 0, 0, time.UTC), 24 * time.Hour},
+        {"spring-forward day is 23 hours", time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), newYork,
+            time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC), 23 * time.Hour},
+        {"fall-back day is 25 hours", time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC), newYork,
+            time.Date(2024, 11, 3, 4, 0, 0, 0, time.UTC), 25 * time.Hour},
+        {"week across fall-back", time.Date(2024, 10, 28, 12, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC), newYork,
+            time.Date(2024, 10, 28, 4, 0, 0, 0, time.UTC), 7*24*time.Hour + time.Hour},
+        // Момент, который в UTC ещё 2 сентября, в Нью-Йорке уже 1 сентября вечером.
+        {"day is taken in the location", time.Date(2024, 9, 2, 2, 0, 0, 0, time.UTC), time.Date(2024, 9, 2, 2, 0, 0, 0, time.UTC), newYork,
+            time.Date(2024, 9, 1, 4, 0, 0, 0, time.UTC), 24 * time.Hour},
+        {"UTC fallback", time.Date(2024, 9, 2, 2, 0, 0, 0, time.UTC), time.Date(2024, 9, 2, 2, 0, 0, 0, time.UTC), locationOrUTC("Mars/Olympus"),
+            time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC), 24 * time.Hour},
+    }
+    for _, c := range cases {
+        start, end := localDayBounds(c.from, c.to, c.loc)
+        if !start.Equal(c.wantStart) || end.Sub(start) != c.wantLen {
+            t.Errorf("%s: localDayBounds = [%v, %v), want start %v and length %v",
+                c.name, start.UTC(), end.UTC(), c.wantStart, c.wantLen)
+        }
+    }
+}
+
+func TestLocationOrUTC(t *testing.T) {
+    loadTestLocation(t, "Europe/Moscow")
+
+    cases := []struct {
+        tz   string
+        want string
+    }{
+        {"Europe/Moscow", "Europe/Moscow"},
+        {"", "UTC"},
+        {"Local", "UTC"},
+        {"Mars/Olympus", "UTC"},
+    }
+    for _, c := range cases {
+        if got := locationOrUTC(c.tz).String(); got != c.want {
+            t.Errorf("locationOrUTC(%q) = %s, want %s", c.tz, got, c.want)
+        }
+    }
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "database/sql"
+    "encoding/base32"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Параметры TOTP (RFC 6238) — те, что понимают все приложения-аутентификаторы.
+const (
+    totpIssuer  = "StudentPlanner"
+    totpDigits  = 6
+    totpPeriod  = 30 * time.Second
+    totpSkew    = 1 // сколько соседних интервалов принимать из-за расхождения часов
+    totpSecretN = 20
+
+    recoveryCodeCount = 10
+
+    loginChallengeTTL         = 5 * time.Minute
+    loginChallengeMaxAttempts = 5
+)
+
+// totpClock — источник времени для кодов и сроков жизни входа; подменяется
+// фиксированным временем, чтобы проверять коды без сети и реальных часов.
+var totpClock = time.Now
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCounter — номер 30-секундного интервала для момента t.
+func totpCounter(t time.Time) int64 {
+    return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// totpCode вычисляет код HOTP (RFC 4226) для счётчика counter.
+func totpCode(secret []byte, counter int64) string {
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+    mac := hmac.New(sha1.New, secret)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigits, value%uint32(math.Pow10(totpDigits)))
+}
+
+// matchTOTP ищет код среди интервалов вокруг now и возвращает счётчик совпавшего.
+// Счётчики не больше lastCounter отвергаются, чтобы один код нельзя было использовать дважды.
+func matchTOTP(secret, code string, now time.Time, lastCounter int64) (int64, bool) {
+    key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+    if err != nil || len(code) != totpDigits {
+        return 0, false
+    }
+
+    current := totpCounter(now)
+    for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+        if counter <= lastCounter {
+            continue
+        }
+        if subtle.ConstantTimeCompare([]byte(totpCode(key, counter)), []byte(code)) == 1 {
+            return counter, true
+        }
+    }
+    return 0, false
+}
+
+func newTOTPSecret() (string, error) {
+    buf := make([]byte, totpSecretN)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return totpEncoding.EncodeToString(buf), nil
+}
+
+// totpProvisioningURI — ссылка otpauth://, которую клиент показывает QR-кодом.
+func totpProvisioningURI(secret, email string) string {
+    params := url.Values{}
+    params.Set("secret", secret)
+    params.Set("issuer", totpIssuer)
+    params.Set("algorithm", "SHA1")
+    params.Set("digits", fmt.Sprint(totpDigits))
+    params.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+    return "otpauth://totp/" + url.PathEscape(totpIssuer+":"+email) + "?" + params.Encode()
+}
+
+// normalizeRecoveryCode приводит код к виду, в котором он хешировался: без дефисов и пробелов.
+func normalizeRecoveryCode(code string) string {
+    code = strings.ToLower(code)
+    return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// replaceRecoveryCodes заменяет резервные коды пользователя новыми и возвращает их
+// в открытом виде — единственный раз, когда их можно увидеть.
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+    if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = $1", userID); err != nil {
+        return nil, err
+    }
+
+    codes := make([]string, recoveryCodeCount)
+    for i := range codes {
+        buf := make([]byte, 6)
+        if _, err := rand.Read(buf); err != nil {
+            return nil, err
+        }
+        raw := strings.ToLower(totpEncoding.EncodeToString(buf))[:10]
+        codes[i] = raw[:5] + "-" + raw[5:]
+
+        hash, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
+        if err != nil {
+            return nil, err
+        }
+        if _, err := tx.Exec(
+            "INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+            userID, string(hash),
+        ); err != nil {
+            return nil, err
+        }
+    }
+    return codes, nil
+}
+
+// verifySecondFactor проверяет код из приложения или неиспользованный резервный код.
+// Совпавший код сразу помечается использованным.
+func verifySecondFactor(userID int, code string, now time.Time) (bool, error) {
+    code = strings.TrimSpace(code)
+
+    var secret sql.NullString
+    var lastCounter int64
+    err := db.QueryRow(
+        "SELECT totp_secret, totp_last_counter FROM users WHERE id = $1 AND totp_enabled_at IS NOT NULL",
+        userID,
+    ).Scan(&secret, &lastCounter)
+    if err == sql.ErrNoRows {
+        return false, nil
+    } else if err != nil {
+        return false, err
+    }
+
+    if counter, ok := matchTOTP(secret.String, code, now, lastCounter); ok {
+        // Условие на счётчик не даёт двум параллельным запросам принять один код.
+        result, err := db.Exec(
+            "UPDATE users SET totp_last_counter = $1 WHERE id = $2 AND totp_last_counter < $1",
+            counter, userID,
+        )
+        if err != nil {
+            return false, err
+        }
+        rowsAffected, _ := result.RowsAffected()
+        return rowsAffected > 0, nil
+    }
+
+    rows, err := db.Query(
+        "SELECT id, code_hash FROM totp_recovery_codes WHERE user_id = $1 AND used_at IS NULL",
+        userID,
+    )
+    if err != nil {
+        return false, err
+    }
+    defer rows.Close()
+
+    normalized := normalizeRecoveryCode(code)
+    for rows.Next() {
+        var id int
+        var hash string
+        if err := rows.Scan(&id, &hash); err != nil {
+            continue
+        }
+        if bcrypt.CompareHashAndPassword([]byte(hash), []byte(normalized)) != nil {
+            continue
+        }
+
+        result, err := db.Exec(
+            "UPDATE totp_recovery_codes SET used_at = $1 WHERE id = $2 AND used_at IS NULL",
+            now, id,
+        )
+        if err != nil {
+            return false, err
+        }
+        rowsAffected, _ := result.RowsAffected()
+        return rowsAffected > 0, nil
+    }
+    return false, rows.Err()
+}
+
+// startLoginChallenge выдаёт одноразовый токен второго шага входа вместо сессии и отвечает
+// 202: вход ещё не завершён. В базе хранится только хеш токена.
+func startLoginChallenge(w http.ResponseWriter, userID int) {
+    token, err := newToken()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка входа"}`, http.StatusInternalServerError)
+        return
+    }
+    expiresAt := totpClock().Add(loginChallengeTTL)
+
+    _, err = db.Exec(
+        "INSERT INTO login_challenges (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+        hashToken(token), userID, expiresAt,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка входа"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "two_factor_required": true,
+        "challenge":           token,
+        "expires_at":          expiresAt,
+    })
+}
+
+// loginChallengeEmail возвращает email пользователя, которому выдан ещё не истёкший
+// токен второго шага, или пустую строку.
+func loginChallengeEmail(challenge string) string {
+    var email string
+    db.QueryRow(
+        `SELECT u.email FROM login_challenges c JOIN users u ON u.id = c.user_id
+         WHERE c.token_hash = $1 AND c.expires_at > $2`,
+        hashToken(challenge), totpClock(),
+    ).Scan(&email)
+    return email
+}
+
+// LoginTwoFactor — второй шаг входа: токен из Login и код из приложения или резервный код.
+// Сессию открывает только верный код. После loginChallengeMaxAttempts ошибок токен
+// аннулируется и вход начинается заново.
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Challenge string `json:"challenge"`
+        Code      string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    now := totpClock()
+    challengeHash := hashToken(req.Challenge)
+
+    var userID int
+    err := db.QueryRow(
+        `SELECT user_id FROM login_challenges
+         WHERE token_hash = $1 AND expires_at > $2 AND attempts < $3`,
+        challengeHash, now, loginChallengeMaxAttempts,
+    ).Scan(&userID)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Время входа истекло, войдите заново"}`, http.StatusUnauthorized)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+        return
+    }
+
+    ok, err := verifySecondFactor(userID, req.Code, now)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки кода"}`, http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        db.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = $1", challengeHash)
+        http.Error(w, `{"error": "Неверный код подтверждения"}`, http.StatusUnauthorized)
+        return
+    }
+
+    db.Exec("DELETE FROM login_challenges WHERE token_hash = $1 OR expires_at < $2", challengeHash, now)
+
+    var user User
+    var locked bool
+    err = db.QueryRow(
+        "SELECT id, email, name, timezone, role, totp_enabled_at IS NOT NULL, locked_at IS NOT NULL, created_at FROM users WHERE id = $1",
+        userID,
+    ).Scan(&user.ID, &user.Email, &user.Name, &user.Timezone, &user.Role, &user.TwoFactorEnabled, &locked, &user.CreatedAt)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if locked {
+        http.Error(w, `{"error": "Учётная запись заблокирована"}`, http.StatusForbidden)
+        return
+    }
+
+    writeSession(w, http.StatusOK, user)
+}
+
+// GetTwoFactorStatus — включена ли 2FA и сколько резервных кодов осталось.
+func GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var status struct {
+        Enabled       bool `json:"enabled"`
+        Pending       bool `json:"pending"`
+        RecoveryCodes int  `json:"recovery_codes_left"`
+    }
+    err := db.QueryRow(
+        `SELECT totp_enabled_at IS NOT NULL, totp_secret IS NOT NULL AND totp_enabled_at IS NULL,
+                (SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = users.id AND used_at IS NULL)
+         FROM users WHERE id = $1`,
+        userID,
+    ).Scan(&status.Enabled, &status.Pending, &status.RecoveryCodes)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(status)
+}
+
+// SetupTwoFactor начинает подключение: создаёт секрет и ссылку для QR-кода.
+// 2FA включается только после подтверждения кодом в EnableTwoFactor.
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var email string
+    var enabled bool
+    err := db.QueryRow(
+        "SELECT email, totp_enabled_at IS NOT NULL FROM users WHERE id = $1",
+        userID,
+    ).Scan(&email, &enabled)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Пользователь не найден"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if enabled {
+        http.Error(w, `{"error": "Двухфакторная аутентификация уже включена"}`, http.StatusConflict)
+        return
+    }
+    if strings.EqualFold(email, demoEmail) {
+        http.Error(w, `{"error": "Двухфакторная аутентификация недоступна для демо-аккаунта"}`, http.StatusForbidden)
+        return
+    }
+
+    secret, err := newTOTPSecret()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка подключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if _, err := db.Exec(
+        "UPDATE users SET totp_secret = $1, totp_last_counter = 0 WHERE id = $2",
+        secret, userID,
+    ); err != nil {
+        http.Error(w, `{"error": "Ошибка подключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        "secret":      secret,
+        "otpauth_uri": totpProvisioningURI(secret, email),
+    })
+}
+
+// EnableTwoFactor подтверждает подключение кодом из приложения и выдаёт резервные коды.
+func EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Code string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    var secret sql.NullString
+    var enabled bool
+    err := db.QueryRow(
+        "SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = $1",
+        userID,
+    ).Scan(&secret, &enabled)
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка базы данных"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if enabled {
+        http.Error(w, `{"error": "Двухфакторная аутентификация уже включена"}`, http.StatusConflict)
+        return
+    }
+    if !secret.Valid {
+        writeBadRequest(w, "Сначала начните подключение 2FA")
+        return
+    }
+
+    counter, ok := matchTOTP(secret.String, strings.TrimSpace(req.Code), totpClock(), 0)
+    if !ok {
+        writeBadRequest(w, "Неверный код подтверждения")
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка подключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec(
+        "UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_counter = $1 WHERE id = $2",
+        counter, userID,
+    ); err != nil {
+        http.Error(w, `{"error": "Ошибка подключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+
+    codes, err := replaceRecoveryCodes(tx, userID)
+    if err != nil || tx.Commit() != nil {
+        http.Error(w, `{"error": "Ошибка подключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// DisableTwoFactor отключает 2FA; нужен действующий код из приложения или резервный код.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Code string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    ok, err := verifySecondFactor(userID, req.Code, totpClock())
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки кода"}`, http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        writeBadRequest(w, "Неверный код подтверждения")
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка отключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    statements := []string{
+        "UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0 WHERE id = $1",
+        "DELETE FROM totp_recovery_codes WHERE user_id = $1",
+        "DELETE FROM login_challenges WHERE user_id = $1",
+    }
+    for _, statement := range statements {
+        if _, err := tx.Exec(statement, userID); err != nil {
+            http.Error(w, `{"error": "Ошибка отключения 2FA"}`, http.StatusInternalServerError)
+            return
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        http.Error(w, `{"error": "Ошибка отключения 2FA"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Двухфакторная аутентификация отключена"})
+}
+
+// RegenerateRecoveryCodes выдаёт новый набор резервных кодов; старые перестают действовать.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    var req struct {
+        Code string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, `{"error": "Неверный формат данных"}`, http.StatusBadRequest)
+        return
+    }
+
+    ok, err := verifySecondFactor(userID, req.Code, totpClock())
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка проверки кода"}`, http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        writeBadRequest(w, "Неверный код подтверждения")
+        return
+    }
+
+    tx, err := db.Begin()
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка создания резервных кодов"}`, http.StatusInternalServerError)
+        return
+    }
+    defer tx.Rollback()
+
+    codes, err := replaceRecoveryCodes(tx, userID)
+    if err != nil || tx.Commit() != nil {
+        http.Error(w, `{"error": "Ошибка создания резервных кодов"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+// rfcSecret — ключ из приложений RFC 4226 и RFC 6238 (SHA-1).
+var rfcSecret = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC4226(t *testing.T) {
+    // RFC 4226, приложение D: HOTP для счётчиков 0..9.
+    want := []string{
+        "755224", "287082", "359152", "969429", "338314",
+        "254676", "287922", "162583", "399871", "520489",
+    }
+    for counter, code := range want {
+        if got := totpCode(rfcSecret, int64(counter)); got != code {
+            t.Errorf("totpCode(%d) = %s, want %s", counter, got, code)
+        }
+    }
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+    // RFC 6238, приложение B, SHA-1; у нас шесть цифр, поэтому берутся младшие
+    // шесть из восьмизначных эталонов.
+    cases := []struct {
+        unix int64
+        code string
+    }{
+        {59, "287082"},
+        {1111111109, "081804"},
+        {1111111111, "050471"},
+        {1234567890, "005924"},
+        {2000000000, "279037"},
+        {20000000000, "353130"},
+    }
+    for _, c := range cases {
+        counter := totpCounter(time.Unix(c.unix, 0))
+        if got := totpCode(rfcSecret, counter); got != c.code {
+            t.Errorf("totpCode at %d = %s, want %s", c.unix, got, c.code)
+        }
+    }
+}
+
+// withTOTPClock фиксирует totpClock на время теста.
+func withTOTPClock(t *testing.T, now time.Time) {
+    saved := totpClock
+    totpClock = func() time.Time { return now }
+    t.Cleanup(func() { totpClock = saved })
+}
+
+func TestMatchTOTPSkew(t *testing.T) {
+    now := time.Unix(1234567890, 0)
+    withTOTPClock(t, now)
+    secret := totpEncoding.EncodeToString(rfcSecret)
+    current := totpCounter(now)
+
+    cases := []struct {
+        name    string
+        counter int64
+        ok      bool
+    }{
+        {"current", current, true},
+        {"previous", current - 1, true},
+        {"next", current + 1, true},
+        {"two behind", current - 2, false},
+        {"two ahead", current + 2, false},
+    }
+    for _, c := range cases {
+        code := totpCode(rfcSecret, c.counter)
+        counter, ok := matchTOTP(secret, code, totpClock(), 0)
+        if ok != c.ok {
+            t.Errorf("%s: matchTOTP ok = %v, want %v", c.name, ok, c.ok)
+        }
+        if ok && counter != c.counter {
+            t.Errorf("%s: matchTOTP counter = %d, want %d", c.name, counter, c.counter)
+        }
+    }
+}
+
+func TestMatchTOTPRejectsReplay(t *testing.T) {
+    now := time.Unix(1234567890, 0)
+    withTOTPClock(t, now)
+    secret := totpEncoding.EncodeToString(rfcSecret)
+    code := totpCode(rfcSecret, totpCounter(now))
+
+    lastCounter, ok := matchTOTP(secret, code, totpClock(), 0)
+    if !ok {
+        t.Fatal("first use of the code was rejected")
+    }
+
+    // totp_last_counter после первого входа равен счётчику кода: тот же код
+    // и коды из более ранних интервалов больше не принимаются.
+    if _, ok := matchTOTP(secret, code, totpClock(), lastCounter); ok {
+        t.Error("the same code was accepted twice")
+    }
+    previous := totpCode(rfcSecret, lastCounter-1)
+    if _, ok := matchTOTP(secret, previous, totpClock(), lastCounter); ok {
+        t.Error("a code older than the last accepted one was accepted")
+    }
+
+    // Код следующего интервала по-прежнему проходит.
+    next := totpCode(rfcSecret, lastCounter+1)
+    if _, ok := matchTOTP(secret, next, totpClock(), lastCounter); !ok {
+        t.Error("the next code was rejected")
+    }
+}
+
+func TestMatchTOTPMalformed(t *testing.T) {
+    now := time.Unix(1234567890, 0)
+    secret := totpEncoding.EncodeToString(rfcSecret)
+    code := totpCode(rfcSecret, totpCounter(now))
+
+    if _, ok := matchTOTP(secret, code[:5], now, 0); ok {
+        t.Error("a short code was accepted")
+    }
+    if _, ok := matchTOTP("not base32!", code, now, 0); ok {
+        t.Error("a code was accepted with an invalid secret")
+    }
+    // Секрет в нижнем регистре, как его вводят вручную, тоже принимается.
+    lower := "gezdgnbvgy3tqojqgezdgnbvgy3tqojq"
+    if _, ok := matchTOTP(lower, code, now, 0); !ok {
+        t.Error("a lowercase secret was rejected")
+    }
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+    cases := map[string]string{
+        "abcde-fghij":   "abcdefghij",
+        "ABCDE-FGHIJ":   "abcdefghij",
+        "abcde fghij":   "abcdefghij",
+        " abc-de-fghij": "abcdefghij",
+        "abcdefghij":    "abcdefghij",
+    }
+    for in, want := range cases {
+        if got := normalizeRecoveryCode(in); got != want {
+            t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
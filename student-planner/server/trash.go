@@ -0,0 +1,245 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "log"
+    "net/http"
+    "os"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+const defaultTrashRetentionDays = 30
+
+// trashRetention — сколько удалённые события и задачи хранятся в корзине до окончательного удаления.
+var trashRetention = defaultTrashRetentionDays * 24 * time.Hour
+
+// trashRetentionFromEnv читает срок хранения корзины из TRASH_RETENTION_DAYS.
+func trashRetentionFromEnv() time.Duration {
+    days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+    if err != nil || days <= 0 {
+        days = defaultTrashRetentionDays
+    }
+    return time.Duration(days) * 24 * time.Hour
+}
+
+type TrashItem struct {
+    Type      string    `json:"type"`
+    ID        int       `json:"id"`
+    Title     string    `json:"title"`
+    Subtasks  int       `json:"subtasks,omitempty"`
+    DeletedAt time.Time `json:"deleted_at"`
+    PurgeAt   time.Time `json:"purge_at"`
+}
+
+// trashedSubtreeCTE выбирает удалённую задачу $1 пользователя $2 вместе с подзадачами,
+// удалёнными одновременно с ней.
+const trashedSubtreeCTE = `WITH RECURSIVE trashed AS (
+        SELECT id, deleted_at FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+        UNION ALL
+        SELECT t.id, t.deleted_at FROM tasks t JOIN trashed ON t.parent_id = trashed.id
+        WHERE t.deleted_at = trashed.deleted_at
+    )`
+
+// purgeTrash окончательно удаляет всё, что пролежало в корзине дольше retention.
+func purgeTrash(retention time.Duration) (int64, int64, error) {
+    cutoff := time.Now().Add(-retention)
+
+    result, err := db.Exec("DELETE FROM events WHERE deleted_at < $1", cutoff)
+    if err != nil {
+        return 0, 0, err
+    }
+    events, _ := result.RowsAffected()
+
+    result, err = db.Exec("DELETE FROM tasks WHERE deleted_at < $1", cutoff)
+    if err != nil {
+        return events, 0, err
+    }
+    tasks, _ := result.RowsAffected()
+
+    // У item_shares нет внешнего ключа на объект, доступы к удалённому убираются здесь.
+    _, err = db.Exec(
+        `DELETE FROM item_shares s
+         WHERE (s.entity_type = 'event' AND NOT EXISTS (SELECT 1 FROM events WHERE id = s.entity_id))
+            OR (s.entity_type = 'task' AND NOT EXISTS (SELECT 1 FROM tasks WHERE id = s.entity_id))`,
+    )
+    return events, tasks, err
+}
+
+// startTrashPurger раз в interval очищает корзину в фоне.
+func startTrashPurger(retention, interval time.Duration) {
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+            events, tasks, err := purgeTrash(retention)
+            if err != nil {
+                log.Printf("Ошибка очистки корзины: %v", err)
+            } else if events > 0 || tasks > 0 {
+                log.Printf("Корзина очищена: событий %d, задач %d", events, tasks)
+            }
+            <-ticker.C
+        }
+    }()
+}
+
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    // Подзадачи, удалённые вместе с родителем, показываются внутри него, а не отдельно.
+    rows, err := db.Query(
+        `SELECT 'event', id, title, 0, deleted_at FROM events
+         WHERE user_id = $1 AND deleted_at IS NOT NULL
+         UNION ALL
+         SELECT 'task', t.id, t.title,
+                (SELECT COUNT(*) FROM tasks c WHERE c.parent_id = t.id AND c.deleted_at = t.deleted_at),
+                t.deleted_at
+         FROM tasks t LEFT JOIN tasks p ON p.id = t.parent_id
+         WHERE t.user_id = $1 AND t.deleted_at IS NOT NULL
+           AND (p.id IS NULL OR p.deleted_at IS DISTINCT FROM t.deleted_at)
+         ORDER BY 5 DESC`,
+        userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка получения корзины"}`, http.StatusInternalServerError)
+        return
+    }
+    defer rows.Close()
+
+    items := []TrashItem{}
+    for rows.Next() {
+        var item TrashItem
+        if err := rows.Scan(&item.Type, &item.ID, &item.Title, &item.Subtasks, &item.DeletedAt); err != nil {
+            continue
+        }
+        item.PurgeAt = item.DeletedAt.Add(trashRetention)
+        items = append(items, item)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "retention_days": int(trashRetention.Hours() / 24),
+        "items":          items,
+    })
+}
+
+func RestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+    userID := getUserIdFromRequest(r)
+    if userID == 0 {
+        http.Error(w, `{"error": "Неавторизованный доступ"}`, http.StatusUnauthorized)
+        return
+    }
+
+    vars := mux.Vars(r)
+    id, _ := strconv.Atoi(vars["id"])
+
+    switch vars["type"] {
+    case "event", "events":
+        restoreEvent(w, id, userID)
+    case "task", "tasks":
+        restoreTask(w, id, userID)
+    default:
+        http.Error(w, `{"error": "Неизвестный тип объекта"}`, http.StatusBadRequest)
+    }
+}
+
+func restoreEvent(w http.ResponseWriter, eventID, userID int) {
+    var roomID sql.NullInt64
+    var startsAt time.Time
+    var durationHours float64
+    err := db.QueryRow(
+        `SELECT room_id, starts_at, duration_hours FROM events
+         WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+        eventID, userID,
+    ).Scan(&roomID, &startsAt, &durationHours)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Событие не найдено в корзине"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления события"}`, http.StatusInternalServerError)
+        return
+    }
+
+    // Пока событие лежало в корзине, аудиторию могли занять.
+    if roomID.Valid {
+        conflicts, err := findRoomConflicts(db, userID, int(roomID.Int64), eventID, startsAt, durationHours)
+        if err != nil {
+            http.Error(w, `{"error": "Ошибка проверки занятости аудитории"}`, http.StatusInternalServerError)
+            return
+        }
+        if len(conflicts) > 0 {
+            writeRoomConflicts(w, conflicts)
+            return
+        }
+    }
+
+    before := snapshotRow("event", eventID)
+    if _, err := db.Exec("UPDATE events SET deleted_at = NULL WHERE id = $1", eventID); err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления события"}`, http.StatusInternalServerError)
+        return
+    }
+    recordHistory("event", eventID, userID, "restore", before)
+
+    event, err := scanEvent(db.QueryRow(
+        `SELECT `+eventColumns("$2")+` FROM events WHERE id = $1`,
+        eventID, userID,
+    ), userLocation(userID))
+    if err != nil {
+        http.Error(w, `{"error": "Событие восстановлено, но не получено"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(event)
+}
+
+func restoreTask(w http.ResponseWriter, taskID, userID int) {
+    var parentDeleted sql.NullBool
+    err := db.QueryRow(
+        `SELECT p.deleted_at IS NOT NULL
+         FROM tasks t LEFT JOIN tasks p ON p.id = t.parent_id
+         WHERE t.id = $1 AND t.user_id = $2 AND t.deleted_at IS NOT NULL`,
+        taskID, userID,
+    ).Scan(&parentDeleted)
+    if err == sql.ErrNoRows {
+        http.Error(w, `{"error": "Задача не найдена в корзине"}`, http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+
+    if parentDeleted.Bool {
+        http.Error(w, `{"error": "Сначала восстановите родительскую задачу"}`, http.StatusConflict)
+        return
+    }
+
+    before := snapshotRow("task", taskID)
+    _, err = db.Exec(
+        trashedSubtreeCTE+`
+        UPDATE tasks SET deleted_at = NULL WHERE id IN (SELECT id FROM trashed)`,
+        taskID, userID,
+    )
+    if err != nil {
+        http.Error(w, `{"error": "Ошибка восстановления задачи"}`, http.StatusInternalServerError)
+        return
+    }
+    recordHistory("task", taskID, userID, "restore", before)
+
+    task, err := loadTaskTree(taskID, userID)
+    if err != nil {
+        http.Error(w, `{"error": "Задача восстановлена, но не получена"}`, http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(task)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Запросы к пользователям, общие для HTTP-обработчиков и подкоманд сервера.
+
+var errUserExists = errors.New("пользователь с таким email уже существует")
+
+// createUser хеширует пароль и сохраняет нового пользователя. Email, как и при поиске,
+// сравнивается без учёта регистра: Foo@x.ru и foo@x.ru — один аккаунт.
+func createUser(email, password, name, timezone, role string) (User, error) {
+    email = strings.TrimSpace(email)
+
+    var existingID int
+    err := db.QueryRow("SELECT id FROM users WHERE LOWER(email) = LOWER($1)", email).Scan(&existingID)
+    if err == nil {
+        return User{}, errUserExists
+    } else if err != sql.ErrNoRows {
+        return User{}, err
+    }
+
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return User{}, err
+    }
+
+    user := User{Email: email, Name: name, Timezone: timezone, Role: role}
+    err = db.QueryRow(
+        "INSERT INTO users (email, password, name, timezone, role) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+        email, string(hashedPassword), name, timezone, role,
+    ).Scan(&user.ID, &user.CreatedAt)
+    if isUniqueViolation(err) {
+        return User{}, errUserExists
+    }
+    return user, err
+}
+
+// findUserByEmail ищет пользователя без учёта регистра email.
+func findUserByEmail(email string) (User, error) {
+    var user User
+    err := db.QueryRow(
+        "SELECT id, email, name, timezone, role, created_at FROM users WHERE LOWER(email) = LOWER($1)",
+        strings.TrimSpace(email),
+    ).Scan(&user.ID, &user.Email, &user.Name, &user.Timezone, &user.Role, &user.CreatedAt)
+    return user, err
+}
+
+// listUsers возвращает пользователей с количеством их событий и задач.
+// q ищет по email и имени, role и lockedOnly сужают выборку.
+func listUsers(q, role string, lockedOnly bool) ([]AdminUser, error) {
+    query := `SELECT ` + adminUserColumns + ` FROM users u WHERE TRUE`
+    args := []interface{}{}
+    if q = strings.TrimSpace(q); q != "" {
+        args = append(args, "%"+strings.ToLower(q)+"%")
+        query += fmt.Sprintf(" AND (LOWER(u.email) LIKE $%d OR LOWER(u.name) LIKE $%d)", len(args), len(args))
+    }
+    if role != "" {
+        args = append(args, role)
+        query += fmt.Sprintf(" AND u.role = $%d", len(args))
+    }
+    if lockedOnly {
+        query += " AND u.locked_at IS NOT NULL"
+    }
+    query += " ORDER BY u.id"
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    users := []AdminUser{}
+    for rows.Next() {
+        user, err := scanAdminUser(rows)
+        if err != nil {
+            continue
+        }
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+// updateUserPassword хеширует и сохраняет новый пароль и завершает сессии, открытые
+// со старым. Возвращает false, если пользователя нет.
+func updateUserPassword(userID int, password string) (bool, error) {
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return false, err
+    }
+
+    result, err := db.Exec("UPDATE users SET password = $1 WHERE id = $2", string(hashedPassword), userID)
+    if err != nil {
+        return false, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 {
+        return false, nil
+    }
+    return true, deleteUserSessions(userID)
+}
+
+// updateUserLock блокирует или разблокирует учётную запись; время первой блокировки сохраняется.
+// Блокировка завершает сессии, так что после разблокировки нужно войти заново.
+func updateUserLock(userID int, locked bool) (bool, error) {
+    lockedAt := "NULL"
+    if locked {
+        lockedAt = "COALESCE(locked_at, CURRENT_TIMESTAMP)"
+    }
+
+    result, err := db.Exec("UPDATE users SET locked_at = "+lockedAt+" WHERE id = $1", userID)
+    if err != nil {
+        return false, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+    if rowsAffected == 0 || !locked {
+        return rowsAffected > 0, nil
+    }
+    return true, deleteUserSessions(userID)
+}
+
+// updateUserRole меняет роль пользователя. Возвращает false, если пользователя нет.
+func updateUserRole(userID int, role string) (bool, error) {
+    result, err := db.Exec("UPDATE users SET role = $1 WHERE id = $2", role, userID)
+    if err != nil {
+        return false, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+    return rowsAffected > 0, nil
+}
+
+// promoteAdmin назначает администратором пользователя с указанным email.
+func promoteAdmin(email string) (bool, error) {
+    result, err := db.Exec(
+        "UPDATE users SET role = $1 WHERE LOWER(email) = LOWER($2) AND role <> $1",
+        roleAdmin, strings.TrimSpace(email),
+    )
+    if err != nil {
+        return false, err
+    }
+    rowsAffected, _ := result.RowsAffected()
+    return rowsAffected > 0, nil
+}